@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/MDavidCV/go-web-module/cmd/server"
 	"github.com/joho/godotenv"
@@ -16,11 +17,78 @@ func main() {
 
 	PORT := os.Getenv("PORT")
 	API_KEY := os.Getenv("API_KEY")
+	STORAGE_DRIVER := os.Getenv("STORAGE_DRIVER")
+	PRODUCTS_FILE_PATH := os.Getenv("PRODUCTS_FILE_PATH")
+	DATABASE_PATH := os.Getenv("DATABASE_PATH")
+	PRICING_RULES_PATH := os.Getenv("PRICING_RULES_PATH")
+	CURRENCY := os.Getenv("CURRENCY")
+	DATE_FORMAT := os.Getenv("DATE_FORMAT")
+	JWT_SECRET := os.Getenv("JWT_SECRET")
+	JWKS_PATH := os.Getenv("JWKS_PATH")
+	JWT_AUDIENCE := os.Getenv("JWT_AUDIENCE")
+	POLICY_PATH := os.Getenv("POLICY_PATH")
+	SIGNING_SECRETS_PATH := os.Getenv("SIGNING_SECRETS_PATH")
+	TLS_CERT_FILE := os.Getenv("TLS_CERT_FILE")
+	TLS_KEY_FILE := os.Getenv("TLS_KEY_FILE")
+	TLS_MIN_VERSION := os.Getenv("TLS_MIN_VERSION")
+	TLS_CIPHER_POLICY := os.Getenv("TLS_CIPHER_POLICY")
+	TLS_CLIENT_CA_FILE := os.Getenv("TLS_CLIENT_CA_FILE")
+	TLS_CLIENT_AUTH := os.Getenv("TLS_CLIENT_AUTH")
+	TLS_CLIENT_IDENTITIES_PATH := os.Getenv("TLS_CLIENT_IDENTITIES_PATH")
+
+	var expiryCheckInterval time.Duration
+	if value := os.Getenv("EXPIRY_CHECK_INTERVAL"); value != "" {
+		if expiryCheckInterval, err = time.ParseDuration(value); err != nil {
+			panic("Invalid EXPIRY_CHECK_INTERVAL: " + err.Error())
+		}
+	}
+
+	var signatureMaxSkew time.Duration
+	if value := os.Getenv("SIGNATURE_MAX_SKEW"); value != "" {
+		if signatureMaxSkew, err = time.ParseDuration(value); err != nil {
+			panic("Invalid SIGNATURE_MAX_SKEW: " + err.Error())
+		}
+	}
+
+	var tlsReloadInterval time.Duration
+	if value := os.Getenv("TLS_RELOAD_INTERVAL"); value != "" {
+		if tlsReloadInterval, err = time.ParseDuration(value); err != nil {
+			panic("Invalid TLS_RELOAD_INTERVAL: " + err.Error())
+		}
+	}
+
+	var cartTTL time.Duration
+	if value := os.Getenv("CART_TTL"); value != "" {
+		if cartTTL, err = time.ParseDuration(value); err != nil {
+			panic("Invalid CART_TTL: " + err.Error())
+		}
+	}
 
 	cfg := &server.ConfigSeverChi{
-		ServerAddress:  ":" + PORT,
-		LoaderFielPath: "/Users/dcastrillonv/Documents/meli-boootcamp/go/go-web/go-web-module/docs/db/products.json",
-		Token:          API_KEY,
+		ServerAddress:           ":" + PORT,
+		LoaderFielPath:          PRODUCTS_FILE_PATH,
+		Token:                   API_KEY,
+		StorageDriver:           STORAGE_DRIVER,
+		DatabasePath:            DATABASE_PATH,
+		PricingRulesPath:        PRICING_RULES_PATH,
+		Currency:                CURRENCY,
+		DateFormat:              DATE_FORMAT,
+		ExpiryCheckInterval:     expiryCheckInterval,
+		CartTTL:                 cartTTL,
+		JWTSecret:               JWT_SECRET,
+		JWKSPath:                JWKS_PATH,
+		JWTAudience:             JWT_AUDIENCE,
+		PolicyPath:              POLICY_PATH,
+		SigningSecretsPath:      SIGNING_SECRETS_PATH,
+		SignatureMaxSkew:        signatureMaxSkew,
+		TLSCertFile:             TLS_CERT_FILE,
+		TLSKeyFile:              TLS_KEY_FILE,
+		TLSMinVersion:           TLS_MIN_VERSION,
+		TLSCipherPolicy:         TLS_CIPHER_POLICY,
+		TLSClientCAFile:         TLS_CLIENT_CA_FILE,
+		TLSClientAuth:           TLS_CLIENT_AUTH,
+		TLSClientIdentitiesPath: TLS_CLIENT_IDENTITIES_PATH,
+		TLSReloadInterval:       tlsReloadInterval,
 	}
 
 	app := server.NewServerChi(cfg)
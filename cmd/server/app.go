@@ -1,14 +1,22 @@
 package server
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/domain"
 	"github.com/MDavidCV/go-web-module/internal/handler/controller"
 	mw "github.com/MDavidCV/go-web-module/internal/handler/middleware"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
 	"github.com/MDavidCV/go-web-module/internal/repository"
 	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/internal/tlsconfig"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -17,23 +25,132 @@ type ConfigSeverChi struct {
 	ServerAddress string
 	// LoaderFilePath is the path to the data that will be loaded into the server.
 	LoaderFielPath string
-	// Token is the token to validate the requests.
+	// Token is the secret of the default API key, stored when the API key file has no default key yet. No default
+	// key is stored when empty.
 	Token string
+	// APIKeysFilePath is the path to the file with the hashed API keys.
+	APIKeysFilePath string
+	// OrdersFilePath is the path to the orders file, used when StorageDriver is "json".
+	OrdersFilePath string
+	// StorageDriver selects the product persistence backend: "json" or "sqlite".
+	StorageDriver string
+	// DatabasePath is the path to the SQLite database file, used when StorageDriver is "sqlite".
+	DatabasePath string
+	// PricingRulesPath is the JSON or YAML file with the consumer price rules. The default rules are used when empty.
+	PricingRulesPath string
+	// Currency is the ISO 4217 currency of prices stored as plain numbers.
+	Currency string
+	// DateFormat is the format dates are written with: "dd/mm/yyyy" or "iso8601".
+	DateFormat string
+	// ExpiryCheckInterval is how often expired products are unpublished.
+	ExpiryCheckInterval time.Duration
+	// CartTTL is how long a cart is kept after its last change, at least a second.
+	CartTTL time.Duration
+	// JWTSecret is the HMAC secret of HS256 bearer tokens.
+	JWTSecret string
+	// JWKSPath is the JSON Web Key Set file with the keys of RS256 and ES256 bearer tokens.
+	JWKSPath string
+	// JWTAudience, when set, must be an audience of every bearer token.
+	JWTAudience string
+	// PolicyPath is the JSON or YAML file with the access policy. The default policy is used when empty.
+	PolicyPath string
+	// SigningSecretsPath is the JSON or YAML file mapping clients, by API key id or token subject, to the secret
+	// they sign their product writes with. Clients without a secret do not sign their requests.
+	SigningSecretsPath string
+	// SignatureMaxSkew is how far the timestamp of a signed request can be from the clock of the server.
+	SignatureMaxSkew time.Duration
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key of the server. It serves plain HTTP when empty.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSMinVersion is the lowest TLS version accepted: "1.2" or "1.3".
+	TLSMinVersion string
+	// TLSCipherPolicy selects the TLS 1.2 cipher suites accepted: "modern" or "compatible".
+	TLSCipherPolicy string
+	// TLSClientCAFile is the PEM bundle client certificates are verified against. A client with a verified
+	// certificate and no other credentials is authenticated as the identity of its certificate.
+	TLSClientCAFile string
+	// TLSClientAuth is "optional" or "require", whether clients must present a certificate.
+	TLSClientAuth string
+	// TLSClientIdentitiesPath is the JSON or YAML file mapping client certificate subjects to identities, which the
+	// access policy grants roles to under certs. Certificates that map to no identity are rejected.
+	TLSClientIdentitiesPath string
+	// TLSReloadInterval is how often the certificate files are checked for changes.
+	TLSReloadInterval time.Duration
 }
 
+// dateLayouts maps the accepted DateFormat values to their layout.
+var dateLayouts = map[string]string{
+	"dd/mm/yyyy": domain.DateLayoutDMY,
+	"iso8601":    domain.DateLayoutISO,
+}
+
+const (
+	StorageDriverJSON   = "json"
+	StorageDriverSQLite = "sqlite"
+)
+
+// minCartTTL is the shortest CartTTL accepted, abandoned carts are looked for every quarter of it.
+const minCartTTL = time.Second
+
 type ServerChi struct {
 	// ServerAddress is the address where the server will listen and serve requests.
 	serverAddress string
 	// LoaderFilePath is the path to the data that will be loaded into the server.
 	loaderFilePath string
-	// Token is the token to validate the requests.
+	// Token is the secret of the default API key.
 	token string
+	// APIKeysFilePath is the path to the file with the hashed API keys.
+	apiKeysFilePath string
+	// OrdersFilePath is the path to the orders file.
+	ordersFilePath string
+	// StorageDriver selects the product persistence backend.
+	storageDriver string
+	// DatabasePath is the path to the SQLite database file.
+	databasePath string
+	// PricingRulesPath is the JSON or YAML file with the consumer price rules.
+	pricingRulesPath string
+	// Currency is the ISO 4217 currency of prices stored as plain numbers.
+	currency string
+	// DateFormat is the format dates are written with.
+	dateFormat string
+	// ExpiryCheckInterval is how often expired products are unpublished.
+	expiryCheckInterval time.Duration
+	// CartTTL is how long a cart is kept after its last change.
+	cartTTL time.Duration
+	// JWTSecret is the HMAC secret of HS256 bearer tokens.
+	jwtSecret string
+	// JWKSPath is the JSON Web Key Set file with the keys of RS256 and ES256 bearer tokens.
+	jwksPath string
+	// JWTAudience is the audience bearer tokens must have.
+	jwtAudience string
+	// PolicyPath is the JSON or YAML file with the access policy.
+	policyPath string
+	// SigningSecretsPath is the JSON or YAML file with the signing secrets of clients.
+	signingSecretsPath string
+	// SignatureMaxSkew is how far the timestamp of a signed request can be from the clock of the server.
+	signatureMaxSkew time.Duration
+	// TLS is the TLS configuration of the server, used when its certificate file is set.
+	tls tlsconfig.Config
+	// TLSClientIdentitiesPath is the JSON or YAML file mapping client certificate subjects to identities.
+	tlsClientIdentitiesPath string
+	// TLSReloadInterval is how often the certificate files are checked for changes.
+	tlsReloadInterval time.Duration
 }
 
 func NewServerChi(cfg *ConfigSeverChi) *ServerChi {
 	defaultConfig := &ConfigSeverChi{
-		ServerAddress: ":8080",
-		Token:         "12345",
+		ServerAddress:       ":8080",
+		LoaderFielPath:      "docs/db/products.json",
+		APIKeysFilePath:     "docs/db/api_keys.json",
+		OrdersFilePath:      "docs/db/orders.json",
+		StorageDriver:       StorageDriverJSON,
+		DatabasePath:        "docs/db/products.db",
+		Currency:            domain.DefaultCurrency,
+		DateFormat:          "dd/mm/yyyy",
+		ExpiryCheckInterval: time.Hour,
+		CartTTL:             24 * time.Hour,
+		SignatureMaxSkew:    5 * time.Minute,
+		TLSReloadInterval:   time.Minute,
 	}
 
 	if cfg != nil {
@@ -46,45 +163,289 @@ func NewServerChi(cfg *ConfigSeverChi) *ServerChi {
 		if cfg.Token != "" {
 			defaultConfig.Token = cfg.Token
 		}
+		if cfg.APIKeysFilePath != "" {
+			defaultConfig.APIKeysFilePath = cfg.APIKeysFilePath
+		}
+		if cfg.OrdersFilePath != "" {
+			defaultConfig.OrdersFilePath = cfg.OrdersFilePath
+		}
+		if cfg.StorageDriver != "" {
+			defaultConfig.StorageDriver = cfg.StorageDriver
+		}
+		if cfg.DatabasePath != "" {
+			defaultConfig.DatabasePath = cfg.DatabasePath
+		}
+		if cfg.PricingRulesPath != "" {
+			defaultConfig.PricingRulesPath = cfg.PricingRulesPath
+		}
+		if cfg.Currency != "" {
+			defaultConfig.Currency = cfg.Currency
+		}
+		if cfg.DateFormat != "" {
+			defaultConfig.DateFormat = cfg.DateFormat
+		}
+		if cfg.ExpiryCheckInterval > 0 {
+			defaultConfig.ExpiryCheckInterval = cfg.ExpiryCheckInterval
+		}
+		if cfg.CartTTL > 0 {
+			defaultConfig.CartTTL = cfg.CartTTL
+		}
+		defaultConfig.JWTSecret = cfg.JWTSecret
+		defaultConfig.JWKSPath = cfg.JWKSPath
+		defaultConfig.JWTAudience = cfg.JWTAudience
+		defaultConfig.PolicyPath = cfg.PolicyPath
+		defaultConfig.SigningSecretsPath = cfg.SigningSecretsPath
+		if cfg.SignatureMaxSkew > 0 {
+			defaultConfig.SignatureMaxSkew = cfg.SignatureMaxSkew
+		}
+		defaultConfig.TLSCertFile = cfg.TLSCertFile
+		defaultConfig.TLSKeyFile = cfg.TLSKeyFile
+		defaultConfig.TLSMinVersion = cfg.TLSMinVersion
+		defaultConfig.TLSCipherPolicy = cfg.TLSCipherPolicy
+		defaultConfig.TLSClientCAFile = cfg.TLSClientCAFile
+		defaultConfig.TLSClientAuth = cfg.TLSClientAuth
+		defaultConfig.TLSClientIdentitiesPath = cfg.TLSClientIdentitiesPath
+		if cfg.TLSReloadInterval > 0 {
+			defaultConfig.TLSReloadInterval = cfg.TLSReloadInterval
+		}
 	}
 
 	return &ServerChi{
-		serverAddress:  defaultConfig.ServerAddress,
-		loaderFilePath: defaultConfig.LoaderFielPath,
-		token:          defaultConfig.Token,
+		serverAddress:       defaultConfig.ServerAddress,
+		loaderFilePath:      defaultConfig.LoaderFielPath,
+		token:               defaultConfig.Token,
+		apiKeysFilePath:     defaultConfig.APIKeysFilePath,
+		ordersFilePath:      defaultConfig.OrdersFilePath,
+		storageDriver:       defaultConfig.StorageDriver,
+		databasePath:        defaultConfig.DatabasePath,
+		pricingRulesPath:    defaultConfig.PricingRulesPath,
+		currency:            defaultConfig.Currency,
+		dateFormat:          defaultConfig.DateFormat,
+		expiryCheckInterval: defaultConfig.ExpiryCheckInterval,
+		cartTTL:             defaultConfig.CartTTL,
+		jwtSecret:           defaultConfig.JWTSecret,
+		jwksPath:            defaultConfig.JWKSPath,
+		jwtAudience:         defaultConfig.JWTAudience,
+		policyPath:          defaultConfig.PolicyPath,
+		signingSecretsPath:  defaultConfig.SigningSecretsPath,
+		signatureMaxSkew:    defaultConfig.SignatureMaxSkew,
+		tls: tlsconfig.Config{
+			CertFile:     defaultConfig.TLSCertFile,
+			KeyFile:      defaultConfig.TLSKeyFile,
+			MinVersion:   defaultConfig.TLSMinVersion,
+			CipherPolicy: defaultConfig.TLSCipherPolicy,
+			ClientCAFile: defaultConfig.TLSClientCAFile,
+			ClientAuth:   defaultConfig.TLSClientAuth,
+		},
+		tlsClientIdentitiesPath: defaultConfig.TLSClientIdentitiesPath,
+		tlsReloadInterval:       defaultConfig.TLSReloadInterval,
 	}
 }
 
 func (s *ServerChi) Run() error {
-	storage := repository.NewStorageProduct("/Users/dcastrillonv/Documents/meli-boootcamp/go/go-web/go-web-module/docs/db/products.json")
-	repository := repository.NewRepositoryProduct(nil, storage)
-	service := service.NewServiceProduct(repository)
-	controller := controller.NewProductController(service)
+	domain.DefaultCurrency = strings.ToUpper(s.currency)
+
+	dateLayout, ok := dateLayouts[strings.ToLower(s.dateFormat)]
+	if !ok {
+		return fmt.Errorf("unknown date format %q", s.dateFormat)
+	}
+	domain.DateLayout = dateLayout
+
+	if s.cartTTL < minCartTTL {
+		return fmt.Errorf("cart TTL %v is shorter than %v", s.cartTTL, minCartTTL)
+	}
+
+	storage := repository.NewStorageProduct(s.loaderFilePath)
+
+	var repo repository.RepositoryProduct
+	var orderRepo repository.RepositoryOrder
+	switch s.storageDriver {
+	case StorageDriverJSON:
+		var err error
+		if repo, err = repository.NewRepositoryProduct(nil, storage); err != nil {
+			return fmt.Errorf("error loading products: %w", err)
+		}
+
+		orderRepo, err = repository.NewRepositoryOrder(nil, repository.NewStorageOrder(s.ordersFilePath))
+		if err != nil {
+			return fmt.Errorf("error loading orders: %w", err)
+		}
+	case StorageDriverSQLite:
+		db, err := sql.Open("sqlite3", "file:"+s.databasePath+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+		if err != nil {
+			return fmt.Errorf("error opening database: %w", err)
+		}
+		defer db.Close()
+
+		repo, err = repository.NewRepositoryProductSQLite(db, storage)
+		if err != nil {
+			return fmt.Errorf("error initializing database: %w", err)
+		}
+
+		orderRepo, err = repository.NewRepositoryOrderSQLite(db)
+		if err != nil {
+			return fmt.Errorf("error initializing database: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown storage driver %q", s.storageDriver)
+	}
+
+	pricingRules := pricing.DefaultRules()
+	if s.pricingRulesPath != "" {
+		var err error
+		if pricingRules, err = pricing.LoadRules(s.pricingRulesPath); err != nil {
+			return fmt.Errorf("error loading pricing rules: %w", err)
+		}
+	}
+
+	apiKeyRepo, err := repository.NewRepositoryAPIKey(nil, repository.NewStorageAPIKey(s.apiKeysFilePath))
+	if err != nil {
+		return fmt.Errorf("error loading api keys: %w", err)
+	}
+	apiKeyService := service.NewServiceAPIKey(apiKeyRepo)
+	if s.token == "" {
+		log.Println("No API key configured: only the keys already stored are accepted")
+	}
+	if err := apiKeyService.BootstrapAPIKey(s.token); err != nil {
+		return fmt.Errorf("error storing the default api key: %w", err)
+	}
+	apiKeyController := controller.NewAPIKeyController(apiKeyService)
+
+	authMid := mw.AuthValidationMid(apiKeyService)
+	if s.jwtSecret != "" || s.jwksPath != "" {
+		verifier, err := auth.NewVerifier(auth.VerifierConfig{
+			HMACSecret: []byte(s.jwtSecret),
+			JWKSPath:   s.jwksPath,
+			Audience:   s.jwtAudience,
+		})
+		if err != nil {
+			return fmt.Errorf("error loading JWT keys: %w", err)
+		}
+		authMid = mw.CredentialsAuthMid(mw.JWTAuthMid(verifier), authMid)
+	}
+
+	policy := auth.DefaultPolicy()
+	if s.policyPath != "" {
+		var err error
+		if policy, err = auth.LoadPolicy(s.policyPath); err != nil {
+			return fmt.Errorf("error loading access policy: %w", err)
+		}
+	}
+
+	if s.tls.ClientCAFile != "" {
+		var identities auth.CertIdentities
+		if s.tlsClientIdentitiesPath != "" {
+			var err error
+			if identities, err = auth.LoadCertIdentities(s.tlsClientIdentitiesPath); err != nil {
+				return fmt.Errorf("error loading client certificate identities: %w", err)
+			}
+		}
+		authMid = mw.ClientCertAuthMid(identities, authMid)
+	}
+
+	var signingSecrets map[string][]byte
+	if s.signingSecretsPath != "" {
+		var err error
+		if signingSecrets, err = auth.LoadSigningSecrets(s.signingSecretsPath); err != nil {
+			return fmt.Errorf("error loading signing secrets: %w", err)
+		}
+	}
+	signatures := auth.NewSignatureVerifier(signingSecrets, s.signatureMaxSkew)
+
+	productService := service.NewServiceProduct(repo, pricingRules)
+	orderService := service.NewServiceOrder(repo, orderRepo, pricingRules)
+	orderController := controller.NewOrderController(orderService)
+	cartService := service.NewServiceCart(repository.NewRepositoryCart(), repo, pricingRules, s.cartTTL)
+	cartController := controller.NewCartController(cartService)
+	controller := controller.NewProductController(productService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.NewExpiryScheduler(productService, s.expiryCheckInterval).Run(ctx)
+	go service.NewCartExpiryScheduler(cartService, s.cartTTL/4).Run(ctx)
 
 	router := chi.NewRouter()
+	router.Use(mw.RequestIdMid)
 	router.Use(mw.ResponseLoggerMid)
 
 	router.Route("/products", func(r chi.Router) {
-		// Public routes
+		r.Use(mw.OptionalAuthMid(authMid))
+
 		r.Group(func(r chi.Router) {
+			r.Use(mw.RequireScopeMid(policy, auth.ScopeCatalogRead))
 			r.Get("/", controller.GetProducts())
 			r.Get("/{id}", controller.GetProductById())
 			r.Get("/search", controller.SearchProduct())
+			r.Get("/expiring", controller.GetExpiringProducts())
+			r.Get("/expired", controller.GetExpiredProducts())
 			r.Get("/consumer_price", controller.GetConsumerPrice())
+			r.Get("/export", controller.ExportProducts())
 		})
 
-		// Protected routes
 		r.Group(func(r chi.Router) {
-			r.Use(mw.AuthValidationMid)
-			r.Post("/", controller.CreateProduct())
-			r.Put("/{id}", controller.UpdateProduct())
-			r.Delete("/{id}", controller.DeleteProduct())
-			r.Patch("/{id}", controller.UpdatePatchProduct())
+			r.Use(mw.SignatureMid(signatures))
+
+			r.Group(func(r chi.Router) {
+				r.Use(mw.RequireScopeMid(policy, auth.ScopeCatalogWrite))
+				r.Post("/", controller.CreateProduct())
+				r.Post("/bulk", controller.CreateProducts())
+				r.Patch("/bulk", controller.PatchProducts())
+				r.Post("/import", controller.ImportProducts())
+				r.Put("/{id}", controller.UpdateProduct())
+				r.Patch("/{id}", controller.UpdatePatchProduct())
+			})
+
+			r.With(mw.RequireScopeMid(policy, auth.ScopeCatalogDelete)).Delete("/{id}", controller.DeleteProduct())
 		})
 	})
 
-	log.Printf("Server running on %s\n", s.serverAddress)
-	if err := http.ListenAndServe(s.serverAddress, router); err != nil {
+	router.Route("/orders", func(r chi.Router) {
+		r.Use(authMid)
+		r.Post("/", orderController.CreateOrder())
+		r.Get("/{id}", orderController.GetOrderById())
+		r.Post("/{id}/cancel", orderController.CancelOrder())
+	})
+
+	router.Route("/carts", func(r chi.Router) {
+		r.Use(authMid)
+		r.Post("/", cartController.CreateCart())
+		r.Get("/{id}", cartController.GetCart())
+		r.Delete("/{id}", cartController.DeleteCart())
+		r.Post("/{id}/items", cartController.AddCartItem())
+		r.Delete("/{id}/items/{productId}", cartController.RemoveCartItem())
+	})
+
+	router.Route("/admin/api-keys", func(r chi.Router) {
+		r.Use(authMid)
+		r.Use(mw.RequireScopeMid(policy, auth.ScopeAPIKeysAdmin))
+		r.Get("/", apiKeyController.GetAPIKeys())
+		r.Post("/", apiKeyController.IssueAPIKey())
+		r.Post("/{id}/rotate", apiKeyController.RotateAPIKey())
+		r.Delete("/{id}", apiKeyController.RevokeAPIKey())
+	})
+
+	if s.tls.CertFile == "" {
+		log.Printf("Server running on %s\n", s.serverAddress)
+		if err := http.ListenAndServe(s.serverAddress, router); err != nil {
+			return fmt.Errorf("error starting application: %w", err)
+		}
+		return nil
+	}
+
+	certificates, err := tlsconfig.New(s.tls)
+	if err != nil {
+		return fmt.Errorf("error loading certificates: %w", err)
+	}
+	go certificates.Watch(ctx, s.tlsReloadInterval)
+
+	server := &http.Server{
+		Addr:      s.serverAddress,
+		Handler:   router,
+		TLSConfig: certificates.TLSConfig(),
+	}
+
+	log.Printf("Server running on %s with TLS\n", s.serverAddress)
+	if err := server.ListenAndServeTLS("", ""); err != nil {
 		return fmt.Errorf("error starting application: %w", err)
 	}
 
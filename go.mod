@@ -7,9 +7,11 @@ require (
 	github.com/joho/godotenv v1.5.1
 )
 
+require github.com/mattn/go-sqlite3 v1.14.22
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/testify v1.9.0
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
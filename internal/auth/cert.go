@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// CertIdentities maps the subject of client certificates, as written by pkix.Name.String, e.g.
+// "CN=partner-1,O=Acme", to the identity of the client, which Policy.Certs grants roles to.
+type CertIdentities map[string]string
+
+// LoadCertIdentities reads the identities of client certificates from a YAML file when filename ends in .yaml or
+// .yml, and from a JSON file otherwise.
+func LoadCertIdentities(filename string) (CertIdentities, error) {
+	identities, err := loadStringMap(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode certificate identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// Identity returns the identity the subject of cert maps to, or an empty string when it maps to none.
+func (ci CertIdentities) Identity(cert *x509.Certificate) string {
+	return ci[cert.Subject.String()]
+}
@@ -0,0 +1,40 @@
+package auth
+
+import "context"
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying the claims of the authenticated client.
+func WithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the authenticated client of the request of ctx, if any.
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// SubjectFromContext returns the subject of the authenticated client of the request of ctx, or an empty string.
+func SubjectFromContext(ctx context.Context) string {
+	claims, _ := ClaimsFromContext(ctx)
+	return claims.Subject
+}
+
+type scopesKey struct{}
+
+// WithScopes returns a copy of ctx carrying the scopes granted to the client.
+func WithScopes(ctx context.Context, scopes []Scope) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// HasScope reports whether scope was granted to the client of the request of ctx.
+func HasScope(ctx context.Context, scope Scope) bool {
+	scopes, _ := ctx.Value(scopesKey{}).([]Scope)
+	for _, granted := range scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jsonWebKey is a verification key with the algorithm it verifies.
+type jsonWebKey struct {
+	kid string
+	alg string
+	// key is a []byte HMAC secret, an *rsa.PublicKey or an *ecdsa.PublicKey.
+	key any
+}
+
+// rawJSONWebKey holds the members of an RFC 7517 key used by the supported key types.
+type rawJSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+// loadJWKS reads the RSA, P-256 EC and symmetric keys of a JSON Web Key Set file. Keys whose use is not "sig"
+// or of another type or curve are skipped.
+func loadJWKS(filename string) ([]jsonWebKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []rawJSONWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS %s: %w", filename, err)
+	}
+
+	var keys []jsonWebKey
+	for i, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+
+		key, err := parseJSONWebKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWKS %s: key %d: %w", filename, i, err)
+		}
+		if key != nil {
+			keys = append(keys, *key)
+		}
+	}
+
+	return keys, nil
+}
+
+func parseJSONWebKey(raw rawJSONWebKey) (*jsonWebKey, error) {
+	var key jsonWebKey
+	key.kid = raw.Kid
+
+	switch raw.Kty {
+	case "RSA":
+		n, err := decodeBigInt(raw.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(raw.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("e: invalid exponent")
+		}
+		key.alg, key.key = AlgRS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+
+	case "EC":
+		if raw.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(raw.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(raw.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on the P-256 curve")
+		}
+		key.alg, key.key = AlgES256, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(raw.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("k: invalid secret")
+		}
+		key.alg, key.key = AlgHS256, secret
+
+	default:
+		return nil, nil
+	}
+
+	// A key restricted to another algorithm, e.g. RS512, is not usable for the supported ones.
+	if raw.Alg != "" && raw.Alg != key.alg {
+		return nil, nil
+	}
+
+	return &key, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
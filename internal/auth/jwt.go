@@ -0,0 +1,279 @@
+// Package auth verifies the credentials of API clients.
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Signing algorithms of the tokens a verifier accepts.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// defaultLeeway is the clock skew tolerated when checking the exp and nbf claims.
+const defaultLeeway = 30 * time.Second
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	Subject  string
+	Issuer   string
+	Audience []string
+	// ExpiresAt, NotBefore and IssuedAt are zero when the token does not have the claim.
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	// APIKey is the id of the API key the client authenticated with, empty for bearer tokens.
+	APIKey string
+	// Certificate is the identity of the client certificate the client authenticated with, see CertIdentities.
+	Certificate string
+	// Scopes are granted by the credential itself, e.g. the scopes of an API key.
+	Scopes []Scope
+	// All holds every claim of the token, registered ones included, as decoded from JSON with numbers as json.Number.
+	All map[string]any
+}
+
+// Verifier checks bearer tokens.
+type Verifier interface {
+	// Verify checks the signature and the time and audience claims of token. Errors wrap utility.ErrUnauthorized.
+	Verify(token string) (Claims, error)
+}
+
+// VerifierConfig holds the keys and the expectations of a verifier.
+type VerifierConfig struct {
+	// HMACSecret verifies HS256 tokens.
+	HMACSecret []byte
+	// JWKSPath is a local JSON Web Key Set file with the keys that verify RS256, ES256 and HS256 tokens.
+	JWKSPath string
+	// Audience, when set, must be one of the audiences of every token.
+	Audience string
+	// Leeway is the clock skew tolerated on exp and nbf, 30 seconds when zero.
+	Leeway time.Duration
+}
+
+type verifier struct {
+	keys     []jsonWebKey
+	audience string
+	leeway   time.Duration
+	clock    func() time.Time
+}
+
+// NewVerifier returns a verifier with the keys of cfg, which must have at least one.
+func NewVerifier(cfg VerifierConfig) (*verifier, error) {
+	v := &verifier{
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway,
+		clock:    time.Now,
+	}
+	if v.leeway == 0 {
+		v.leeway = defaultLeeway
+	}
+
+	if len(cfg.HMACSecret) > 0 {
+		v.keys = append(v.keys, jsonWebKey{alg: AlgHS256, key: cfg.HMACSecret})
+	}
+
+	if cfg.JWKSPath != "" {
+		keys, err := loadJWKS(cfg.JWKSPath)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = append(v.keys, keys...)
+	}
+
+	if len(v.keys) == 0 {
+		return nil, errors.New("a verifier needs an HMAC secret or a JWKS file")
+	}
+
+	return v, nil
+}
+
+// WithClock sets the clock the time claims are checked against.
+func (v *verifier) WithClock(clock func() time.Time) *verifier {
+	v.clock = clock
+	return v
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", utility.ErrUnauthorized, reason)
+}
+
+func (v *verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, invalidToken("malformed token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, invalidToken("malformed token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, invalidToken("malformed token signature")
+	}
+
+	if !v.verifySignature(header, []byte(parts[0]+"."+parts[1]), signature) {
+		return Claims{}, invalidToken("invalid token signature")
+	}
+
+	var all map[string]any
+	if err := decodeSegment(parts[1], &all); err != nil || all == nil {
+		return Claims{}, invalidToken("malformed token claims")
+	}
+
+	claims, err := parseClaims(all)
+	if err != nil {
+		return Claims{}, invalidToken(err.Error())
+	}
+
+	now := v.clock()
+	if claims.ExpiresAt.IsZero() {
+		return Claims{}, invalidToken("token has no expiry")
+	}
+	if !now.Before(claims.ExpiresAt.Add(v.leeway)) {
+		return Claims{}, invalidToken("token expired")
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.leeway).Before(claims.NotBefore) {
+		return Claims{}, invalidToken("token not valid yet")
+	}
+
+	if v.audience != "" && !contains(claims.Audience, v.audience) {
+		return Claims{}, invalidToken("token not issued for this audience")
+	}
+
+	return claims, nil
+}
+
+// verifySignature checks signature with every key of the algorithm of header, or only the key of its kid if it has one.
+// The algorithm of a key is fixed by the key, so a token cannot choose how its signature is checked, e.g. an
+// RSA public key is never used as an HMAC secret.
+func (v *verifier) verifySignature(header tokenHeader, signed []byte, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	for _, key := range v.keys {
+		if key.alg != header.Alg || (header.Kid != "" && key.kid != "" && key.kid != header.Kid) {
+			continue
+		}
+
+		switch key := key.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			mac.Write(signed)
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			// ES256 signatures are the 32 byte r and s concatenated.
+			if len(signature) != 64 {
+				continue
+			}
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if ecdsa.Verify(key, digest[:], r, s) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// parseClaims reads the registered claims of all.
+func parseClaims(all map[string]any) (Claims, error) {
+	claims := Claims{All: all}
+
+	var ok bool
+	if value, found := all["sub"]; found {
+		if claims.Subject, ok = value.(string); !ok {
+			return Claims{}, errors.New("sub claim must be a string")
+		}
+	}
+	if value, found := all["iss"]; found {
+		if claims.Issuer, ok = value.(string); !ok {
+			return Claims{}, errors.New("iss claim must be a string")
+		}
+	}
+
+	switch audience := all["aud"].(type) {
+	case nil:
+	case string:
+		claims.Audience = []string{audience}
+	case []any:
+		for _, item := range audience {
+			value, ok := item.(string)
+			if !ok {
+				return Claims{}, errors.New("aud claim must be a string or an array of strings")
+			}
+			claims.Audience = append(claims.Audience, value)
+		}
+	default:
+		return Claims{}, errors.New("aud claim must be a string or an array of strings")
+	}
+
+	for name, target := range map[string]*time.Time{"exp": &claims.ExpiresAt, "nbf": &claims.NotBefore, "iat": &claims.IssuedAt} {
+		value, found := all[name]
+		if !found {
+			continue
+		}
+
+		number, ok := value.(json.Number)
+		if !ok {
+			return Claims{}, fmt.Errorf("%s claim must be a number", name)
+		}
+		seconds, err := number.Float64()
+		if err != nil {
+			return Claims{}, fmt.Errorf("%s claim must be a number", name)
+		}
+		whole, fraction := math.Modf(seconds)
+		*target = time.Unix(int64(whole), int64(fraction*float64(time.Second)))
+	}
+
+	return claims, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
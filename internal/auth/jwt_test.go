@@ -0,0 +1,207 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func encode(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign returns a token with header and claims signed by key, a []byte HMAC secret, an *rsa.PrivateKey or an
+// *ecdsa.PrivateKey.
+func sign(t *testing.T, header map[string]any, claims map[string]any, key any) string {
+	signed := encode(t, header) + "." + encode(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(overrides map[string]any) map[string]any {
+	claims := map[string]any{"sub": "client-1", "aud": "catalog", "exp": now.Add(time.Hour).Unix()}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func b64(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func writeJWKS(t *testing.T, keys ...map[string]any) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestVerifier(t *testing.T) {
+	secret := []byte("a-secret-of-at-least-32-bytes-long")
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+
+	newVerifier := func(t *testing.T, cfg auth.VerifierConfig) auth.Verifier {
+		verifier, err := auth.NewVerifier(cfg)
+		require.NoError(t, err)
+		return verifier.WithClock(func() time.Time { return now })
+	}
+
+	t.Run("sucess should verify an HS256 token and return its claims", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier(t, auth.VerifierConfig{HMACSecret: secret, Audience: "catalog"})
+		token := sign(t, hs256, claims(map[string]any{"scope": "catalog:read", "aud": []string{"other", "catalog"}}), secret)
+
+		// Act
+		got, err := verifier.Verify(token)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, "client-1", got.Subject)
+		require.Equal(t, []string{"other", "catalog"}, got.Audience)
+		require.Equal(t, now.Add(time.Hour).Unix(), got.ExpiresAt.Unix())
+		require.Equal(t, "catalog:read", got.All["scope"])
+	})
+
+	t.Run("sucess should verify RS256 and ES256 tokens with the keys of a JWKS file", func(t *testing.T) {
+		// Arrange
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		path := writeJWKS(t,
+			map[string]any{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			map[string]any{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+			map[string]any{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+		)
+		verifier := newVerifier(t, auth.VerifierConfig{JWKSPath: path})
+
+		// Act
+		_, rsaErr := verifier.Verify(sign(t, map[string]any{"alg": "RS256", "kid": "rsa-1"}, claims(nil), rsaKey))
+		_, ecErr := verifier.Verify(sign(t, map[string]any{"alg": "ES256", "kid": "ec-1"}, claims(nil), ecKey))
+
+		// Assert
+		require.NoError(t, rsaErr)
+		require.NoError(t, ecErr)
+	})
+
+	t.Run("should reject a token using an RSA public key as HMAC secret", func(t *testing.T) {
+		// Arrange
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		n := b64(rsaKey.N.Bytes())
+		verifier := newVerifier(t, auth.VerifierConfig{JWKSPath: writeJWKS(t, map[string]any{"kty": "RSA", "n": n, "e": "AQAB"})})
+
+		// Act
+		_, err = verifier.Verify(sign(t, hs256, claims(nil), []byte(n)))
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrUnauthorized)
+		require.ErrorContains(t, err, "invalid token signature")
+	})
+
+	cases := []struct {
+		name   string
+		token  func(t *testing.T) string
+		reason string
+	}{
+		{"should reject a token signed with another secret", func(t *testing.T) string {
+			return sign(t, hs256, claims(nil), []byte("another secret"))
+		}, "invalid token signature"},
+		{"should reject an unsigned token", func(t *testing.T) string {
+			return sign(t, map[string]any{"alg": "none"}, claims(nil), []byte{})
+		}, "invalid token signature"},
+		{"should reject an expired token", func(t *testing.T) string {
+			return sign(t, hs256, claims(map[string]any{"exp": now.Add(-time.Minute).Unix()}), secret)
+		}, "token expired"},
+		{"should reject a token without expiry", func(t *testing.T) string {
+			return sign(t, hs256, claims(map[string]any{"exp": nil}), secret)
+		}, "token has no expiry"},
+		{"should reject a token used before nbf", func(t *testing.T) string {
+			return sign(t, hs256, claims(map[string]any{"nbf": now.Add(time.Minute).Unix()}), secret)
+		}, "token not valid yet"},
+		{"should reject a token for another audience", func(t *testing.T) string {
+			return sign(t, hs256, claims(map[string]any{"aud": "billing"}), secret)
+		}, "token not issued for this audience"},
+		{"should reject a malformed token", func(t *testing.T) string {
+			return "not-a-token"
+		}, "malformed token"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			verifier := newVerifier(t, auth.VerifierConfig{HMACSecret: secret, Audience: "catalog"})
+
+			// Act
+			_, err := verifier.Verify(c.token(t))
+
+			// Assert
+			require.ErrorIs(t, err, utility.ErrUnauthorized)
+			require.ErrorContains(t, err, c.reason)
+		})
+	}
+
+	t.Run("sucess should tolerate the configured clock skew", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier(t, auth.VerifierConfig{HMACSecret: secret, Leeway: time.Minute})
+		token := sign(t, hs256, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix(), "nbf": now.Add(30 * time.Second).Unix()}), secret)
+
+		// Act
+		_, err := verifier.Verify(token)
+
+		// Assert
+		require.NoError(t, err)
+	})
+
+	t.Run("should fail without keys", func(t *testing.T) {
+		// Act
+		_, err := auth.NewVerifier(auth.VerifierConfig{})
+
+		// Assert
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scope is a permission required by a route.
+type Scope string
+
+const (
+	ScopeCatalogRead   Scope = "catalog:read"
+	ScopeCatalogWrite  Scope = "catalog:write"
+	ScopeCatalogDelete Scope = "catalog:delete"
+	// ScopeAPIKeysAdmin allows issuing, rotating and revoking API keys.
+	ScopeAPIKeysAdmin Scope = "apikeys:admin"
+)
+
+var knownScopes = map[Scope]bool{
+	ScopeCatalogRead:   true,
+	ScopeCatalogWrite:  true,
+	ScopeCatalogDelete: true,
+	ScopeAPIKeysAdmin:  true,
+}
+
+// IsKnownScope reports whether scope is a scope required by some route.
+func IsKnownScope(scope string) bool {
+	return knownScopes[Scope(scope)]
+}
+
+// Policy maps clients to roles, and roles to the scopes they grant.
+type Policy struct {
+	// Roles maps each role to its scopes.
+	Roles map[string][]Scope `json:"roles" yaml:"roles"`
+	// Anonymous are the roles of requests without credentials.
+	Anonymous []string `json:"anonymous" yaml:"anonymous"`
+	// Authenticated are the roles of every authenticated client.
+	Authenticated []string `json:"authenticated" yaml:"authenticated"`
+	// Subjects maps the subject of bearer tokens to roles.
+	Subjects map[string][]string `json:"subjects" yaml:"subjects"`
+	// APIKeys maps the id of API keys to roles.
+	APIKeys map[string][]string `json:"api_keys" yaml:"api_keys"`
+	// Certs maps the identity of client certificates to roles.
+	Certs map[string][]string `json:"certs" yaml:"certs"`
+	// Claims maps a claim of bearer tokens, and each of its values, to roles. A string claim holds space separated
+	// values, like the scope claim, and an array claim one value per item.
+	Claims map[string]map[string][]string `json:"claims" yaml:"claims"`
+}
+
+// DefaultPolicy returns the historical access: anyone can read the catalog and any authenticated client can
+// change it. Only the API key "default", the configured token, manages API keys.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Roles: map[string][]Scope{
+			"reader": {ScopeCatalogRead},
+			"editor": {ScopeCatalogRead, ScopeCatalogWrite, ScopeCatalogDelete},
+			"admin":  {ScopeAPIKeysAdmin},
+		},
+		Anonymous:     []string{"reader"},
+		Authenticated: []string{"editor"},
+		APIKeys:       map[string][]string{"default": {"admin"}},
+	}
+}
+
+// LoadPolicy reads a policy from a YAML file when filename ends in .yaml or .yml, and from a JSON file otherwise.
+func LoadPolicy(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, policy)
+	default:
+		err = json.Unmarshal(data, policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode access policy: %w", err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Validate checks every scope is known and every role is defined.
+func (p *Policy) Validate() error {
+	for role, scopes := range p.Roles {
+		for _, scope := range scopes {
+			if !knownScopes[scope] {
+				return fmt.Errorf("access policy: role %s: unknown scope %q", role, scope)
+			}
+		}
+	}
+
+	check := func(where string, roles []string) error {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("access policy: %s: unknown role %q", where, role)
+			}
+		}
+		return nil
+	}
+
+	if err := check("anonymous", p.Anonymous); err != nil {
+		return err
+	}
+	if err := check("authenticated", p.Authenticated); err != nil {
+		return err
+	}
+	for subject, roles := range p.Subjects {
+		if err := check("subject "+subject, roles); err != nil {
+			return err
+		}
+	}
+	for id, roles := range p.APIKeys {
+		if err := check("api key "+id, roles); err != nil {
+			return err
+		}
+	}
+	for identity, roles := range p.Certs {
+		if err := check("cert "+identity, roles); err != nil {
+			return err
+		}
+	}
+	for claim, values := range p.Claims {
+		for value, roles := range values {
+			if err := check("claim "+claim+" "+value, roles); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Scopes returns the sorted scopes of the roles of a client, and the scopes of its credential. A client whose
+// credential has scopes does not get the authenticated roles. claims is nil for requests without credentials.
+func (p *Policy) Scopes(claims *Claims) []Scope {
+	granted := map[Scope]bool{}
+
+	var roles []string
+	if claims == nil {
+		roles = p.Anonymous
+	} else {
+		for _, scope := range claims.Scopes {
+			granted[scope] = true
+		}
+		if len(claims.Scopes) == 0 {
+			roles = append(roles, p.Authenticated...)
+		}
+
+		switch {
+		case claims.APIKey != "":
+			roles = append(roles, p.APIKeys[claims.APIKey]...)
+		case claims.Certificate != "":
+			roles = append(roles, p.Certs[claims.Certificate]...)
+		default:
+			roles = append(roles, p.Subjects[claims.Subject]...)
+			for name, values := range p.Claims {
+				for _, value := range claimValues(claims.All[name]) {
+					roles = append(roles, values[value]...)
+				}
+			}
+		}
+	}
+
+	for _, role := range roles {
+		for _, scope := range p.Roles[role] {
+			granted[scope] = true
+		}
+	}
+
+	scopes := make([]Scope, 0, len(granted))
+	for scope := range granted {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i] < scopes[j] })
+
+	return scopes
+}
+
+// claimValues returns the space separated values of a string claim, or the string items of an array claim.
+func claimValues(claim any) []string {
+	switch claim := claim.(type) {
+	case string:
+		return strings.Fields(claim)
+	case []any:
+		values := make([]string, 0, len(claim))
+		for _, item := range claim {
+			if value, ok := item.(string); ok {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy(t *testing.T) {
+	writePolicy := func(t *testing.T, name string, content string) string {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("sucess should grant the scopes of the roles of subjects, claims and api keys", func(t *testing.T) {
+		// Arrange
+		path := writePolicy(t, "policy.yaml", `
+roles:
+  reader: [catalog:read]
+  writer: [catalog:write]
+  admin: [catalog:read, catalog:write, catalog:delete]
+anonymous: []
+authenticated: [reader]
+subjects:
+  client-1: [writer]
+api_keys:
+  ci: [admin]
+certs:
+  partner-1: [admin]
+claims:
+  scope:
+    catalog.admin: [admin]
+`)
+
+		// Act
+		policy, err := auth.LoadPolicy(path)
+
+		// Assert
+		require.NoError(t, err)
+		require.Empty(t, policy.Scopes(nil))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogRead}, policy.Scopes(&auth.Claims{Subject: "client-2"}))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogRead, auth.ScopeCatalogWrite}, policy.Scopes(&auth.Claims{Subject: "client-1"}))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogDelete, auth.ScopeCatalogRead, auth.ScopeCatalogWrite},
+			policy.Scopes(&auth.Claims{Subject: "client-2", All: map[string]any{"scope": "openid catalog.admin"}}))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogDelete, auth.ScopeCatalogRead, auth.ScopeCatalogWrite},
+			policy.Scopes(&auth.Claims{Subject: "ci", APIKey: "ci"}))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogRead}, policy.Scopes(&auth.Claims{Subject: "client-1", Certificate: "client-1"}))
+		require.Equal(t, []auth.Scope{auth.ScopeCatalogDelete, auth.ScopeCatalogRead, auth.ScopeCatalogWrite},
+			policy.Scopes(&auth.Claims{Subject: "partner-1", Certificate: "partner-1"}))
+	})
+
+	t.Run("should reject unknown scopes and roles", func(t *testing.T) {
+		// Act
+		_, scopeErr := auth.LoadPolicy(writePolicy(t, "policy.json", `{"roles": {"reader": ["catalog:list"]}}`))
+		_, roleErr := auth.LoadPolicy(writePolicy(t, "policy.json", `{"roles": {"reader": ["catalog:read"]}, "subjects": {"client-1": ["writer"]}}`))
+
+		// Assert
+		require.ErrorContains(t, scopeErr, `unknown scope "catalog:list"`)
+		require.ErrorContains(t, roleErr, `unknown role "writer"`)
+	})
+}
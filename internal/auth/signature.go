@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/utility"
+	"gopkg.in/yaml.v3"
+)
+
+// Headers of a signed request.
+const (
+	HeaderSignature = "X-Signature"
+	// HeaderTimestamp holds the Unix time, in seconds, the request was signed at.
+	HeaderTimestamp = "X-Signature-Timestamp"
+	// HeaderNonce holds a value unique to the request, which cannot be sent again while its timestamp is accepted.
+	HeaderNonce = "X-Signature-Nonce"
+)
+
+// defaultMaxSkew is how far the timestamp of a signed request can be from the clock of the server.
+const defaultMaxSkew = 5 * time.Minute
+
+// SignedRequest holds the parts of a request covered by its signature.
+type SignedRequest struct {
+	Method string
+	// Path is the path of the request with its query, as sent, e.g. /products/bulk?mode=best_effort.
+	Path      string
+	Timestamp string
+	Nonce     string
+	Body      []byte
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of req with secret: the MAC of the method, path, timestamp, nonce
+// and hex encoded SHA-256 of the body, each followed by a new line.
+func Sign(secret []byte, req SignedRequest) string {
+	bodyHash := sha256.Sum256(req.Body)
+
+	mac := hmac.New(sha256.New, secret)
+	for _, part := range []string{strings.ToUpper(req.Method), req.Path, req.Timestamp, req.Nonce, hex.EncodeToString(bodyHash[:])} {
+		mac.Write([]byte(part))
+		mac.Write([]byte("\n"))
+	}
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureVerifier checks the signature of requests.
+type SignatureVerifier interface {
+	// Requires reports whether the requests of subject must be signed.
+	Requires(subject string) bool
+	// Verify checks req was signed by subject with signature. Errors wrap utility.ErrUnauthorized.
+	Verify(subject string, req SignedRequest, signature string) error
+}
+
+type signatureVerifier struct {
+	// secrets maps the subject of clients to the secret their requests are signed with.
+	secrets map[string][]byte
+	maxSkew time.Duration
+	clock   func() time.Time
+
+	// mu guards nonces, which maps the nonces seen in the last 2*maxSkew to when they can be forgotten, and
+	// expiries, the same nonces in the order they were seen, so expired ones are evicted from its front.
+	mu       sync.Mutex
+	nonces   map[string]time.Time
+	expiries []usedNonce
+}
+
+type usedNonce struct {
+	nonce string
+	until time.Time
+}
+
+// NewSignatureVerifier returns a verifier of the requests of the clients of secrets, a map of subjects to their
+// signing secret. maxSkew is 5 minutes when zero.
+func NewSignatureVerifier(secrets map[string][]byte, maxSkew time.Duration) *signatureVerifier {
+	if maxSkew == 0 {
+		maxSkew = defaultMaxSkew
+	}
+
+	return &signatureVerifier{
+		secrets: secrets,
+		maxSkew: maxSkew,
+		clock:   time.Now,
+		nonces:  map[string]time.Time{},
+	}
+}
+
+// WithClock sets the clock timestamps are checked against.
+func (v *signatureVerifier) WithClock(clock func() time.Time) *signatureVerifier {
+	v.clock = clock
+	return v
+}
+
+func (v *signatureVerifier) Requires(subject string) bool {
+	_, ok := v.secrets[subject]
+	return ok
+}
+
+// Verify checks req was signed with the secret of subject, within the allowed skew, and that its nonce was not
+// used before.
+func (v *signatureVerifier) Verify(subject string, req SignedRequest, signature string) error {
+	secret, ok := v.secrets[subject]
+	if !ok {
+		return invalidSignature("no signing secret for the client")
+	}
+
+	if req.Timestamp == "" || req.Nonce == "" || signature == "" {
+		return invalidSignature(fmt.Sprintf("%s, %s and %s are required", HeaderSignature, HeaderTimestamp, HeaderNonce))
+	}
+
+	seconds, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return invalidSignature("malformed signature timestamp")
+	}
+	now := v.clock()
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-v.maxSkew)) || signedAt.After(now.Add(v.maxSkew)) {
+		return invalidSignature("signature timestamp outside the allowed clock skew")
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, req)), []byte(strings.ToLower(signature))) {
+		return invalidSignature("invalid request signature")
+	}
+
+	// Only verified requests record their nonce, so forged requests cannot burn the nonces of others.
+	if !v.useNonce(subject+"\n"+req.Nonce, now) {
+		return invalidSignature("signature nonce already used")
+	}
+
+	return nil
+}
+
+// useNonce records nonce and reports whether it was unused. A nonce is kept for 2*maxSkew, past which any request
+// carrying it has an expired timestamp.
+func (v *signatureVerifier) useNonce(nonce string, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Nonces are kept for the same time, so they expire in the order they were seen.
+	expired := 0
+	for _, used := range v.expiries {
+		if !now.After(used.until) {
+			break
+		}
+		delete(v.nonces, used.nonce)
+		expired++
+	}
+	v.expiries = v.expiries[expired:]
+
+	if _, ok := v.nonces[nonce]; ok {
+		return false
+	}
+	until := now.Add(2 * v.maxSkew)
+	v.nonces[nonce] = until
+	v.expiries = append(v.expiries, usedNonce{nonce: nonce, until: until})
+
+	return true
+}
+
+func invalidSignature(reason string) error {
+	return fmt.Errorf("%w: %s", utility.ErrUnauthorized, reason)
+}
+
+// LoadSigningSecrets reads the signing secrets of clients, a map of subjects to secrets, from a YAML file when
+// filename ends in .yaml or .yml, and from a JSON file otherwise.
+func LoadSigningSecrets(filename string) (map[string][]byte, error) {
+	raw, err := loadStringMap(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode signing secrets: %w", err)
+	}
+
+	secrets := make(map[string][]byte, len(raw))
+	for subject, secret := range raw {
+		if secret == "" {
+			return nil, fmt.Errorf("signing secrets: %s: empty secret", subject)
+		}
+		secrets[subject] = []byte(secret)
+	}
+
+	return secrets, nil
+}
+
+// loadStringMap reads a map of strings from a YAML file when filename ends in .yaml or .yml, and from a JSON file
+// otherwise.
+func loadStringMap(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		err = json.Unmarshal(data, &values)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,144 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	secret := []byte("partner-secret")
+	newRequest := func(nonce string, at time.Time) auth.SignedRequest {
+		return auth.SignedRequest{
+			Method:    "PATCH",
+			Path:      "/products/bulk?mode=best_effort",
+			Timestamp: strconv.FormatInt(at.Unix(), 10),
+			Nonce:     nonce,
+			Body:      []byte(`[{"id": 1, "quantity": 5}]`),
+		}
+	}
+	newVerifier := func() auth.SignatureVerifier {
+		return auth.NewSignatureVerifier(map[string][]byte{"partner": secret}, time.Minute).WithClock(func() time.Time { return now })
+	}
+
+	t.Run("sucess should verify a request signed with the secret of the client", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier()
+		req := newRequest("n-1", now.Add(-30*time.Second))
+
+		// Act
+		err := verifier.Verify("partner", req, auth.Sign(secret, req))
+
+		// Assert
+		require.NoError(t, err)
+		require.True(t, verifier.Requires("partner"))
+		require.False(t, verifier.Requires("default"))
+	})
+
+	t.Run("should reject a request whose signed parts changed", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier()
+		signed := newRequest("n-1", now)
+		signature := auth.Sign(secret, signed)
+
+		tampered := []func(req *auth.SignedRequest){
+			func(req *auth.SignedRequest) { req.Method = "DELETE" },
+			func(req *auth.SignedRequest) { req.Path = "/products/bulk" },
+			func(req *auth.SignedRequest) { req.Timestamp = strconv.FormatInt(now.Unix()+1, 10) },
+			func(req *auth.SignedRequest) { req.Nonce = "n-2" },
+			func(req *auth.SignedRequest) { req.Body = []byte(`[{"id": 1, "quantity": 0}]`) },
+		}
+		for _, tamper := range tampered {
+			req := signed
+			tamper(&req)
+
+			// Act
+			err := verifier.Verify("partner", req, signature)
+
+			// Assert
+			require.ErrorIs(t, err, utility.ErrUnauthorized)
+			require.ErrorContains(t, err, "invalid request signature")
+		}
+	})
+
+	t.Run("should reject a timestamp outside the allowed skew", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier()
+		late := newRequest("n-1", now.Add(-2*time.Minute))
+		early := newRequest("n-2", now.Add(2*time.Minute))
+
+		// Act
+		lateErr := verifier.Verify("partner", late, auth.Sign(secret, late))
+		earlyErr := verifier.Verify("partner", early, auth.Sign(secret, early))
+
+		// Assert
+		require.ErrorContains(t, lateErr, "outside the allowed clock skew")
+		require.ErrorContains(t, earlyErr, "outside the allowed clock skew")
+	})
+
+	t.Run("should reject a replayed nonce", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier()
+		req := newRequest("n-1", now)
+		require.NoError(t, verifier.Verify("partner", req, auth.Sign(secret, req)))
+
+		// Act
+		err := verifier.Verify("partner", req, auth.Sign(secret, req))
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrUnauthorized)
+		require.ErrorContains(t, err, "nonce already used")
+	})
+
+	t.Run("sucess should forget nonces once their timestamp cannot be accepted anymore", func(t *testing.T) {
+		// Arrange
+		clock := now
+		verifier := auth.NewSignatureVerifier(map[string][]byte{"partner": secret}, time.Minute).WithClock(func() time.Time { return clock })
+		first := newRequest("n-1", clock)
+		require.NoError(t, verifier.Verify("partner", first, auth.Sign(secret, first)))
+
+		clock = now.Add(3 * time.Minute)
+		second := newRequest("n-2", clock)
+		require.NoError(t, verifier.Verify("partner", second, auth.Sign(secret, second)))
+		replayed := newRequest("n-1", clock)
+
+		// Act
+		err := verifier.Verify("partner", replayed, auth.Sign(secret, replayed))
+
+		// Assert
+		require.NoError(t, err)
+	})
+
+	t.Run("should require the signature headers", func(t *testing.T) {
+		// Arrange
+		verifier := newVerifier()
+		req := newRequest("", now)
+
+		// Act
+		err := verifier.Verify("partner", req, auth.Sign(secret, req))
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrUnauthorized)
+	})
+}
+
+func TestLoadSigningSecrets(t *testing.T) {
+	t.Run("sucess should read the secret of each client", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "secrets.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("partner: partner-secret\n"), 0o600))
+
+		// Act
+		secrets, err := auth.LoadSigningSecrets(path)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{"partner": []byte("partner-secret")}, secrets)
+	})
+}
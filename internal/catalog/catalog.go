@@ -0,0 +1,168 @@
+// Package catalog reads and writes products in the exchange formats of the catalog: CSV, NDJSON and
+// the JSON array of docs/db/products.json.
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatJSON   Format = "json"
+)
+
+// mediaTypes is the media type of each format, as written in Content-Type headers.
+var mediaTypes = map[Format]string{
+	FormatCSV:    "text/csv",
+	FormatNDJSON: "application/x-ndjson",
+	FormatJSON:   "application/json",
+}
+
+// csvColumns is the header of CSV exports. The price is the decimal amount in major units of the currency.
+var csvColumns = []string{"id", "name", "quantity", "code_value", "is_published", "expiration", "price", "currency", "version"}
+
+// ParseFormat reads the name of a format, FormatJSON when empty. Errors wrap utility.ErrInvalidQuery.
+func ParseFormat(name string) (Format, error) {
+	if name == "" {
+		return FormatJSON, nil
+	}
+
+	format := Format(name)
+	if _, ok := mediaTypes[format]; !ok {
+		return "", fmt.Errorf("%w: format: expected csv, ndjson or json", utility.ErrInvalidQuery)
+	}
+	return format, nil
+}
+
+// FormatOfMediaType returns the format of uploads of mediaType. application/ndjson is accepted as
+// well as application/x-ndjson.
+func FormatOfMediaType(mediaType string) (Format, bool) {
+	if mediaType == "application/ndjson" {
+		return FormatNDJSON, true
+	}
+
+	for format, formatMediaType := range mediaTypes {
+		if formatMediaType == mediaType {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// MediaType returns the media type of f.
+func (f Format) MediaType() string {
+	return mediaTypes[f]
+}
+
+// Writer writes products one at a time, so a catalog can be streamed.
+type Writer interface {
+	Write(product domain.Product) error
+	// Close writes what the format needs after the last product. It does not close the underlying writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of format to w.
+func NewWriter(w io.Writer, format Format) Writer {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}
+	}
+	return &jsonWriter{w: w}
+}
+
+type csvWriter struct {
+	w          *csv.Writer
+	headerDone bool
+}
+
+func (cw *csvWriter) header() error {
+	if cw.headerDone {
+		return nil
+	}
+	cw.headerDone = true
+	return cw.w.Write(csvColumns)
+}
+
+func (cw *csvWriter) Write(product domain.Product) error {
+	if err := cw.header(); err != nil {
+		return err
+	}
+
+	return cw.w.Write([]string{
+		strconv.Itoa(product.Id),
+		product.Name,
+		strconv.Itoa(product.Quantity),
+		product.CodeValue,
+		strconv.FormatBool(product.IsPublished),
+		product.Expiration.String(),
+		product.Price.Decimal(),
+		product.Price.Currency,
+		strconv.Itoa(product.Version),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	if err := cw.header(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(product domain.Product) error {
+	return nw.encoder.Encode(product)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+// jsonWriter writes a JSON array, one product per line like the storage does.
+type jsonWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (jw *jsonWriter) Write(product domain.Product) error {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if jw.written == 0 {
+		separator = "[\n"
+	}
+	jw.written++
+
+	if _, err := io.WriteString(jw.w, separator); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(data)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	end := "\n]\n"
+	if jw.written == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(jw.w, end)
+	return err
+}
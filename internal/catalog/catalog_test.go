@@ -0,0 +1,148 @@
+package catalog_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/catalog"
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+var products = []domain.Product{
+	{Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.5, ""), Version: 2},
+	{Id: 2, Name: "Product, 2", Quantity: 20, CodeValue: "67890", Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200, "EUR"), Version: 1},
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("sucess should write csv with a header", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		writer := catalog.NewWriter(&buf, catalog.FormatCSV)
+
+		// Act
+		for _, product := range products {
+			require.NoError(t, writer.Write(product))
+		}
+		require.NoError(t, writer.Close())
+
+		// Assert
+		expected := "id,name,quantity,code_value,is_published,expiration,price,currency,version\n" +
+			"1,Product 1,10,12345,true,01/01/2023,100.5,USD,2\n" +
+			"2,\"Product, 2\",20,67890,false,02/01/2023,200,EUR,1\n"
+		require.Equal(t, expected, buf.String())
+	})
+
+	t.Run("sucess should write an empty json array", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		writer := catalog.NewWriter(&buf, catalog.FormatJSON)
+
+		// Act
+		require.NoError(t, writer.Close())
+
+		// Assert
+		require.JSONEq(t, `[]`, buf.String())
+	})
+}
+
+func TestReadRows(t *testing.T) {
+	t.Run("sucess should read back a csv export", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		writer := catalog.NewWriter(&buf, catalog.FormatCSV)
+		for _, product := range products {
+			require.NoError(t, writer.Write(product))
+		}
+		require.NoError(t, writer.Close())
+
+		// Act
+		rows, err := catalog.ReadRows(&buf, catalog.FormatCSV, 100)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, []catalog.Row{
+			{Line: 2, Product: utility.ProductRequest{Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.5, "")}},
+			{Line: 3, Product: utility.ProductRequest{Name: "Product, 2", Quantity: 20, CodeValue: "67890", Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200, "EUR")}},
+		}, rows)
+	})
+
+	t.Run("should report the malformed fields of each row", func(t *testing.T) {
+		// Arrange
+		upload := "name,quantity,code_value,expiration,price\n" +
+			"Product 1,ten,12345,01/01/2023,100\n" +
+			"Product 2,20,67890,2023-13-01,abc\n"
+
+		// Act
+		rows, err := catalog.ReadRows(strings.NewReader(upload), catalog.FormatCSV, 100)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		require.Equal(t, []utility.Violation{{Field: "quantity", Code: "invalid", Message: "quantity must be an integer"}}, rows[0].Err.(*utility.ValidationError).Violations)
+		require.Len(t, rows[1].Err.(*utility.ValidationError).Violations, 2)
+	})
+
+	t.Run("should report rows with missing or extra columns on their own", func(t *testing.T) {
+		// Arrange
+		upload := "name,quantity,code_value,expiration,price\n" +
+			"Product 1,10,12345\n" +
+			"Product 2,20,67890,01/01/2023,100,extra\n" +
+			"Product 3,30,13579,01/01/2023,100\n"
+
+		// Act
+		rows, err := catalog.ReadRows(strings.NewReader(upload), catalog.FormatCSV, 100)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		require.Equal(t, []utility.Violation{{Field: "", Code: "invalid", Message: "the row has 3 columns, the header has 5"}}, rows[0].Err.(*utility.ValidationError).Violations)
+		require.Equal(t, []utility.Violation{{Field: "", Code: "invalid", Message: "the row has 6 columns, the header has 5"}}, rows[1].Err.(*utility.ValidationError).Violations)
+		require.NoError(t, rows[2].Err)
+		require.Equal(t, 4, rows[2].Line)
+	})
+
+	t.Run("should stop reading once the upload has too many rows", func(t *testing.T) {
+		// Arrange
+		csvUpload := "name\na\nb\nc\n"
+		ndjsonUpload := `{"name": "a"}` + "\n" + `{"name": "b"}` + "\n" + `{"name": "c"}` + "\n"
+
+		// Act
+		_, csvErr := catalog.ReadRows(strings.NewReader(csvUpload), catalog.FormatCSV, 2)
+		_, ndjsonErr := catalog.ReadRows(strings.NewReader(ndjsonUpload), catalog.FormatNDJSON, 2)
+
+		// Assert
+		require.ErrorIs(t, csvErr, utility.ErrBulkTooLarge)
+		require.ErrorIs(t, ndjsonErr, utility.ErrBulkTooLarge)
+	})
+
+	t.Run("should reject unknown csv columns", func(t *testing.T) {
+		// Act
+		_, err := catalog.ReadRows(strings.NewReader("name,color\nProduct 1,red\n"), catalog.FormatCSV, 100)
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrInvalidRequestBody)
+	})
+
+	t.Run("sucess should read ndjson rows, skipping blank lines", func(t *testing.T) {
+		// Arrange
+		upload := `{"id": 7, "name": "Product 1", "quantity": 10, "code_value": "12345", "expiration": "01/01/2023", "price": 100, "version": 3}` + "\n\n" +
+			`{"name": "Product 2", "color": "red", "quantity": "many"}` + "\n"
+
+		// Act
+		rows, err := catalog.ReadRows(strings.NewReader(upload), catalog.FormatNDJSON, 100)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		require.NoError(t, rows[0].Err)
+		require.Equal(t, utility.ProductRequest{Name: "Product 1", Quantity: 10, CodeValue: "12345", Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100, "")}, rows[0].Product)
+		require.Equal(t, 3, rows[1].Line)
+		require.Equal(t, []utility.Violation{
+			{Field: "color", Code: "unknown", Message: "color is not a product field"},
+			{Field: "quantity", Code: "invalid", Message: "quantity has an invalid value"},
+		}, rows[1].Err.(*utility.ValidationError).Violations)
+	})
+}
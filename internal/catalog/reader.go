@@ -0,0 +1,232 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/validation"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Row is a product read from an upload.
+type Row struct {
+	// Line is the line of the row in the upload, counting from 1.
+	Line    int
+	Product utility.ProductRequest
+	// Err is a *utility.ValidationError listing the fields of the row that could not be decoded, or nil.
+	// The fields are not checked against the product rules, which is left to validation.ProductRequest.
+	Err error
+}
+
+// ignoredFields are written by exports but not read back: imported products get new ids and versions.
+var ignoredFields = map[string]bool{"id": true, "version": true}
+
+// ReadRows reads every row of an upload in format, FormatCSV or FormatNDJSON. A row that cannot be decoded
+// is reported in its Err, while an error is returned when the upload as a whole cannot be read.
+// Errors wrap utility.ErrInvalidRequestBody, along with the error of r, or utility.ErrBulkTooLarge once the upload
+// has more than limit rows, at which point it is not read any further.
+func ReadRows(r io.Reader, format Format, limit int) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, limit)
+	case FormatNDJSON:
+		return readNDJSON(r, limit)
+	}
+	return nil, fmt.Errorf("%w: %s uploads are not supported", utility.ErrUnsupportedMediaType, format)
+}
+
+func tooManyRows(limit int) error {
+	return fmt.Errorf("%w: at most %d rows", utility.ErrBulkTooLarge, limit)
+}
+
+// csvFields decodes each column of a CSV row into the product. currency is read before price, which uses it.
+var csvFields = map[string]func(req *utility.ProductRequest, value string, currency string) error{
+	"name": func(req *utility.ProductRequest, value string, _ string) error {
+		req.Name = value
+		return nil
+	},
+	"quantity": func(req *utility.ProductRequest, value string, _ string) (err error) {
+		if value == "" {
+			return nil
+		}
+		if req.Quantity, err = strconv.Atoi(value); err != nil {
+			return errors.New("quantity must be an integer")
+		}
+		return nil
+	},
+	"code_value": func(req *utility.ProductRequest, value string, _ string) error {
+		req.CodeValue = value
+		return nil
+	},
+	"is_published": func(req *utility.ProductRequest, value string, _ string) (err error) {
+		if value == "" {
+			return nil
+		}
+		if req.IsPublished, err = strconv.ParseBool(value); err != nil {
+			return errors.New("is_published must be true or false")
+		}
+		return nil
+	},
+	"expiration": func(req *utility.ProductRequest, value string, _ string) (err error) {
+		if value == "" {
+			return nil
+		}
+		if req.Expiration, err = domain.ParseDate(value); err != nil {
+			return fmt.Errorf("expiration: %w", err)
+		}
+		return nil
+	},
+	"price": func(req *utility.ProductRequest, value string, currency string) (err error) {
+		if value == "" {
+			return nil
+		}
+		if req.Price, err = domain.ParseMoney(value, currency); err != nil {
+			return fmt.Errorf("price: %w", err)
+		}
+		return nil
+	},
+}
+
+func readCSV(r io.Reader, limit int) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// Rows with a wrong number of columns are reported on their own rather than failing the upload.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the upload is empty", utility.ErrInvalidRequestBody)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utility.ErrInvalidRequestBody, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := csvFields[name]; !ok && name != "currency" && !ignoredFields[name] {
+			return nil, fmt.Errorf("%w: unknown column %q", utility.ErrInvalidRequestBody, name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q", utility.ErrInvalidRequestBody, name)
+		}
+		columns[name] = i
+	}
+
+	names := make([]string, 0, len(csvFields))
+	for name := range csvFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", utility.ErrInvalidRequestBody, err)
+		}
+		if len(rows) == limit {
+			return nil, tooManyRows(limit)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line}
+
+		var v validation.Violations
+		if len(record) != len(header) {
+			v.Add("", validation.CodeInvalid, fmt.Sprintf("the row has %d columns, the header has %d", len(record), len(header)))
+			row.Err = v.Err()
+			rows = append(rows, row)
+			continue
+		}
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		for _, name := range names {
+			if err := csvFields[name](&row.Product, value(name), value("currency")); err != nil {
+				v.Add(name, validation.CodeInvalid, err.Error())
+			}
+		}
+
+		row.Err = v.Err()
+		rows = append(rows, row)
+	}
+}
+
+// jsonFields is the field of utility.ProductRequest each member of an NDJSON row is decoded into.
+func jsonFields(req *utility.ProductRequest) map[string]any {
+	return map[string]any{
+		"name":         &req.Name,
+		"quantity":     &req.Quantity,
+		"code_value":   &req.CodeValue,
+		"is_published": &req.IsPublished,
+		"expiration":   &req.Expiration,
+		"price":        &req.Price,
+	}
+}
+
+func readNDJSON(r io.Reader, limit int) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows) == limit {
+			return nil, tooManyRows(limit)
+		}
+
+		row := Row{Line: line}
+		row.Err = decodeJSONRow(data, &row.Product)
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", utility.ErrInvalidRequestBody, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the upload is empty", utility.ErrInvalidRequestBody)
+	}
+
+	return rows, nil
+}
+
+// decodeJSONRow decodes every member of data on its own, as product requests are, so all the malformed ones
+// are reported.
+func decodeJSONRow(data []byte, req *utility.ProductRequest) error {
+	var v validation.Violations
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil || members == nil {
+		v.Add("", validation.CodeInvalid, "the row must be a JSON object")
+		return v.Err()
+	}
+
+	for name := range ignoredFields {
+		delete(members, name)
+	}
+	v.DecodeMembers(members, jsonFields(req))
+
+	return v.Err()
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// APIKey is a credential issued to an API client. Only the hash of its secret is kept.
+type APIKey struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Hash is the hex encoded SHA-256 of the key presented by the client.
+	Hash string `json:"hash"`
+	// Scopes are the scopes granted to the key, see the auth package.
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can be used at now: it was not revoked and did not expire.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
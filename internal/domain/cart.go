@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// CartItem is a product put in a cart Quantity times.
+type CartItem struct {
+	ProductId int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+type Cart struct {
+	Id int `json:"id"`
+	// Owner is the subject of the client that created the cart, the only one allowed to see or change it.
+	Owner string     `json:"-"`
+	Items []CartItem `json:"items"`
+	// UpdatedAt is the time of the last change, carts left unchanged for too long are abandoned.
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Date layouts accepted by ParseDate.
+const (
+	DateLayoutDMY = "02/01/2006"
+	DateLayoutISO = "2006-01-02"
+)
+
+// DateLayout is the layout dates are written with in JSON.
+var DateLayout = DateLayoutDMY
+
+var ErrInvalidDate = errors.New("invalid date")
+
+// Date is a calendar day, without time of day nor time zone.
+type Date struct {
+	t time.Time
+}
+
+// NewDate returns the date of the given day.
+func NewDate(year int, month time.Month, day int) Date {
+	return Date{t: time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// DateOf returns the day of t in its own location.
+func DateOf(t time.Time) Date {
+	return NewDate(t.Date())
+}
+
+// ParseDate parses DD/MM/YYYY and ISO 8601 dates, either a plain YYYY-MM-DD or a full timestamp.
+func ParseDate(value string) (Date, error) {
+	value = strings.TrimSpace(value)
+
+	for _, layout := range []string{DateLayoutDMY, DateLayoutISO, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return DateOf(t), nil
+		}
+	}
+
+	return Date{}, fmt.Errorf("%w: %q, expected DD/MM/YYYY or YYYY-MM-DD", ErrInvalidDate, value)
+}
+
+// MustParseDate is like ParseDate but panics on error. It is meant for literals.
+func MustParseDate(value string) Date {
+	date, err := ParseDate(value)
+	if err != nil {
+		panic(err)
+	}
+	return date
+}
+
+func (d Date) IsZero() bool {
+	return d.t.IsZero()
+}
+
+// Time returns midnight UTC of the date.
+func (d Date) Time() time.Time {
+	return d.t
+}
+
+func (d Date) Before(o Date) bool {
+	return d.t.Before(o.t)
+}
+
+func (d Date) After(o Date) bool {
+	return d.t.After(o.t)
+}
+
+// Compare returns -1, 0 or +1 as d is before, equal or after o.
+func (d Date) Compare(o Date) int {
+	return d.t.Compare(o.t)
+}
+
+// AddDays returns the date days later, or earlier when days is negative.
+func (d Date) AddDays(days int) Date {
+	return Date{t: d.t.AddDate(0, 0, days)}
+}
+
+// Format writes the date with layout, or an empty string for the zero date.
+func (d Date) Format(layout string) string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.t.Format(layout)
+}
+
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts every format of ParseDate, and an empty string for the zero date.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: expected a string", ErrInvalidDate)
+	}
+
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+
+	date, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+
+	*d = date
+	return nil
+}
+
+// Value stores dates as sortable YYYY-MM-DD text.
+func (d Date) Value() (driver.Value, error) {
+	return d.Format(DateLayoutISO), nil
+}
+
+func (d *Date) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*d = Date{}
+		return nil
+	case string:
+		return d.scanString(value)
+	case []byte:
+		return d.scanString(string(value))
+	case time.Time:
+		*d = DateOf(value)
+		return nil
+	}
+
+	return fmt.Errorf("%w: cannot scan %T", ErrInvalidDate, src)
+}
+
+func (d *Date) scanString(value string) error {
+	if value == "" {
+		*d = Date{}
+		return nil
+	}
+
+	date, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+
+	*d = date
+	return nil
+}
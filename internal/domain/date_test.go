@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	t.Run("sucess should accept DD/MM/YYYY and ISO 8601 dates", func(t *testing.T) {
+		expected := domain.NewDate(2021, time.December, 15)
+
+		for _, value := range []string{"15/12/2021", "2021-12-15", "2021-12-15T10:30:00Z"} {
+			date, err := domain.ParseDate(value)
+
+			require.NoError(t, err, value)
+			require.Equal(t, 0, date.Compare(expected), value)
+		}
+	})
+
+	t.Run("should reject other formats", func(t *testing.T) {
+		_, err := domain.ParseDate("12/15/2021")
+
+		require.ErrorIs(t, err, domain.ErrInvalidDate)
+	})
+}
+
+func TestDateJSON(t *testing.T) {
+	t.Run("sucess should write dates with DateLayout", func(t *testing.T) {
+		// Arrange
+		defer func(layout string) { domain.DateLayout = layout }(domain.DateLayout)
+		date := domain.NewDate(2021, time.December, 15)
+
+		// Act
+		dmy, err := json.Marshal(date)
+		require.NoError(t, err)
+		domain.DateLayout = domain.DateLayoutISO
+		iso, err := json.Marshal(date)
+		require.NoError(t, err)
+
+		// Assert
+		require.Equal(t, `"15/12/2021"`, string(dmy))
+		require.Equal(t, `"2021-12-15"`, string(iso))
+	})
+}
@@ -0,0 +1,263 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts given as plain numbers, as every price was before
+// currencies were introduced.
+var DefaultCurrency = "USD"
+
+// currencyExponents is the number of minor unit digits of the ISO 4217 currencies that do not use 2.
+var currencyExponents = map[string]int{
+	"BHD": 3,
+	"CLP": 0,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"OMR": 3,
+	"PYG": 0,
+	"TND": 3,
+	"UGX": 0,
+	"VND": 0,
+}
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ErrMoneyOverflow is returned by the arithmetic of Money when the result does not fit in an int64.
+var ErrMoneyOverflow = errors.New("money amount too large")
+
+// Money is an amount in the minor units of an ISO 4217 currency, e.g. 89854 USD for $898.54.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// CurrencyExponent returns the number of minor unit digits of currency.
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+func normalizeCurrency(currency string) (string, error) {
+	if currency == "" {
+		return DefaultCurrency, nil
+	}
+
+	currency = strings.ToUpper(currency)
+	if len(currency) != 3 || strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return "", fmt.Errorf("%w: %q is not an ISO 4217 currency code", ErrInvalidMoney, currency)
+	}
+
+	return currency, nil
+}
+
+// isDecimal reports whether value is a plain decimal number, like "-898.54", without exponent or fraction.
+func isDecimal(value string) bool {
+	isDigits := func(s string) bool { return s != "" && strings.Trim(s, "0123456789") == "" }
+
+	integer, fraction, hasPoint := strings.Cut(strings.TrimPrefix(value, "-"), ".")
+	return isDigits(integer) && (!hasPoint || isDigits(fraction))
+}
+
+// ParseMoney parses a decimal amount in major units, like "898.54" or "898.54 EUR".
+// A currency in value takes precedence over currency, and an empty currency means DefaultCurrency.
+// Digits beyond the minor unit are rounded half away from zero.
+func ParseMoney(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if amount, code, ok := strings.Cut(value, " "); ok {
+		value, currency = amount, strings.TrimSpace(code)
+	}
+
+	currency, err := normalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	if !isDecimal(value) {
+		return Money{}, fmt.Errorf("%w: %q is not a number", ErrInvalidMoney, value)
+	}
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q is not a number", ErrInvalidMoney, value)
+	}
+
+	minor := roundRat(amount.Mul(amount, new(big.Rat).SetInt(pow10(CurrencyExponent(currency)))), RoundHalfUp)
+	if !minor.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %q overflows the amounts of %s", ErrInvalidMoney, value, currency)
+	}
+	return Money{Amount: minor.Int64(), Currency: currency}, nil
+}
+
+// MoneyFromFloat converts a float amount in major units, rounding to the nearest minor unit.
+// It exists for legacy float prices; prefer ParseMoney for anything else.
+func MoneyFromFloat(amount float64, currency string) Money {
+	money, err := ParseMoney(big.NewFloat(amount).Text('f', -1), currency)
+	if err != nil {
+		panic(err)
+	}
+	return money
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Rounding modes of RoundRat.
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundUp       = "up"
+	RoundDown     = "down"
+)
+
+// RoundRat rounds r to an integer: half away from zero, half to even, towards +inf or towards -inf.
+// The result is undefined when the rounded r does not fit in an int64.
+func RoundRat(r *big.Rat, mode string) int64 {
+	return roundRat(r, mode).Int64()
+}
+
+// MoneyFromRat rounds amount, in minor units of currency, with mode. It fails with ErrMoneyOverflow when the
+// rounded amount does not fit in an int64.
+func MoneyFromRat(amount *big.Rat, currency string, mode string) (Money, error) {
+	return Money{Currency: currency}.fromBig(roundRat(amount, mode))
+}
+
+func roundRat(r *big.Rat, mode string) *big.Int {
+	quo, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return quo
+	}
+
+	// Compare 2*|rem| with the denominator to know whether r is below, at or above the half.
+	half := new(big.Int).Abs(rem)
+	half.Lsh(half, 1)
+	cmpHalf := half.Cmp(r.Denom())
+
+	var away bool
+	switch mode {
+	case RoundUp:
+		away = r.Sign() > 0
+	case RoundDown:
+		away = r.Sign() < 0
+	case RoundHalfEven:
+		away = cmpHalf > 0 || cmpHalf == 0 && quo.Bit(0) == 1
+	default:
+		away = cmpHalf >= 0
+	}
+
+	if away {
+		quo.Add(quo, big.NewInt(int64(r.Sign())))
+	}
+	return quo
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// fromBig returns amount in the currency of m, failing with ErrMoneyOverflow when it does not fit in an int64.
+func (m Money) fromBig(amount *big.Int) (Money, error) {
+	if !amount.IsInt64() {
+		return Money{}, fmt.Errorf("%w: %s minor units of %s", ErrMoneyOverflow, amount, m.Currency)
+	}
+	return Money{Amount: amount.Int64(), Currency: m.Currency}, nil
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	return m.fromBig(new(big.Int).Add(big.NewInt(m.Amount), big.NewInt(o.Amount)))
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	return m.fromBig(new(big.Int).Sub(big.NewInt(m.Amount), big.NewInt(o.Amount)))
+}
+
+// Mul returns m multiplied by n.
+func (m Money) Mul(n int) (Money, error) {
+	return m.fromBig(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(int64(n))))
+}
+
+// Rat returns the amount in minor units as an exact rational.
+func (m Money) Rat() *big.Rat {
+	return new(big.Rat).SetInt64(m.Amount)
+}
+
+// Compare returns -1, 0 or +1 as m is lower, equal or greater than o, ordering by currency first.
+func (m Money) Compare(o Money) int {
+	if c := strings.Compare(m.Currency, o.Currency); c != 0 {
+		return c
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Decimal formats the amount in major units without trailing zeros, e.g. "898.5".
+func (m Money) Decimal() string {
+	exponent := CurrencyExponent(m.Currency)
+	text := new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(exponent)).FloatString(exponent)
+	if strings.Contains(text, ".") {
+		text = strings.TrimRight(strings.TrimRight(text, "0"), ".")
+	}
+	return text
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+type moneyJSON struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes amounts in DefaultCurrency as a plain number, as prices always were,
+// and others as {"amount": 898.54, "currency": "EUR"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m.Currency == "" || m.Currency == DefaultCurrency {
+		return []byte(m.Decimal()), nil
+	}
+	return json.Marshal(moneyJSON{Amount: json.Number(m.Decimal()), Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts both forms written by MarshalJSON.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var raw moneyJSON
+	if bytes.HasPrefix(data, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return err
+		}
+	} else {
+		raw.Amount = json.Number(data)
+	}
+
+	money, err := ParseMoney(raw.Amount.String(), raw.Currency)
+	if err != nil {
+		return err
+	}
+
+	*m = money
+	return nil
+}
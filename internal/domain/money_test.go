@@ -0,0 +1,101 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoneyJSON(t *testing.T) {
+	t.Run("sucess should keep default currency prices as plain numbers", func(t *testing.T) {
+		// Arrange
+		var product domain.Product
+
+		// Act
+		err := json.Unmarshal([]byte(`{"id":432,"price":898.54}`), &product)
+		require.NoError(t, err)
+		data, err := json.Marshal(product.Price)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, domain.Money{Amount: 89854, Currency: "USD"}, product.Price)
+		require.Equal(t, "898.54", string(data))
+	})
+
+	t.Run("sucess should round trip other currencies as objects", func(t *testing.T) {
+		// Arrange
+		var price domain.Money
+
+		// Act
+		err := json.Unmarshal([]byte(`{"amount": 1500, "currency": "jpy"}`), &price)
+		require.NoError(t, err)
+		data, err := json.Marshal(price)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, domain.Money{Amount: 1500, Currency: "JPY"}, price)
+		require.JSONEq(t, `{"amount": 1500, "currency": "JPY"}`, string(data))
+	})
+
+	t.Run("should reject an invalid currency", func(t *testing.T) {
+		// Arrange
+		var price domain.Money
+
+		// Act
+		err := json.Unmarshal([]byte(`{"amount": 10, "currency": "EURO"}`), &price)
+
+		// Assert
+		require.ErrorIs(t, err, domain.ErrInvalidMoney)
+	})
+}
+
+func TestParseMoney(t *testing.T) {
+	t.Run("sucess should parse amounts with a currency suffix", func(t *testing.T) {
+		// Act
+		price, err := domain.ParseMoney("12.5 kwd", "")
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, domain.Money{Amount: 12500, Currency: "KWD"}, price)
+		require.Equal(t, "12.5 KWD", price.String())
+	})
+
+	t.Run("should only accept plain decimal amounts", func(t *testing.T) {
+		for _, value := range []string{"1/3", "1e3", "0x10", "1.", ".5", "+1", "1_000"} {
+			// Act
+			_, err := domain.ParseMoney(value, "")
+
+			// Assert
+			require.ErrorIs(t, err, domain.ErrInvalidMoney, value)
+		}
+	})
+
+	t.Run("should reject amounts whose minor units overflow", func(t *testing.T) {
+		// Arrange
+		var product domain.Product
+
+		// Act
+		_, parseErr := domain.ParseMoney("184467440737095517.16", "")
+		jsonErr := json.Unmarshal([]byte(`{"price": 184467440737095517.16}`), &product)
+
+		// Assert
+		require.ErrorIs(t, parseErr, domain.ErrInvalidMoney)
+		require.ErrorIs(t, jsonErr, domain.ErrInvalidMoney)
+	})
+}
+
+func TestRoundRat(t *testing.T) {
+	t.Run("sucess should round halves according to the mode", func(t *testing.T) {
+		half := big.NewRat(5, 2)
+		negativeHalf := big.NewRat(-5, 2)
+
+		require.Equal(t, int64(3), domain.RoundRat(half, domain.RoundHalfUp))
+		require.Equal(t, int64(-3), domain.RoundRat(negativeHalf, domain.RoundHalfUp))
+		require.Equal(t, int64(2), domain.RoundRat(half, domain.RoundHalfEven))
+		require.Equal(t, int64(3), domain.RoundRat(big.NewRat(21, 10), domain.RoundUp))
+		require.Equal(t, int64(2), domain.RoundRat(big.NewRat(29, 10), domain.RoundDown))
+	})
+}
@@ -0,0 +1,29 @@
+package domain
+
+import "time"
+
+type OrderStatus string
+
+const (
+	OrderStatusPlaced    OrderStatus = "placed"
+	OrderStatusCancelled OrderStatus = "cancelled"
+)
+
+// OrderLine is a product bought Quantity times, priced when the order was placed.
+type OrderLine struct {
+	ProductId int   `json:"product_id"`
+	Quantity  int   `json:"quantity"`
+	UnitPrice Money `json:"unit_price"`
+	// Total is the price of the line after its discount.
+	Total Money `json:"total"`
+}
+
+type Order struct {
+	Id     int         `json:"id"`
+	Status OrderStatus `json:"status"`
+	Lines  []OrderLine `json:"lines"`
+	// Total is the amount charged for the order, surcharge included.
+	Total       Money      `json:"total"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
@@ -1,11 +1,20 @@
 package domain
 
+import "strconv"
+
 type Product struct {
-	Id          int     `json:"id"`
-	Name        string  `json:"name"`
-	Quantity    int     `json:"quantity"`
-	CodeValue   string  `json:"code_value"`
-	IsPublished bool    `json:"is_published"`
-	Expiration  string  `json:"expiration"`
-	Price       float64 `json:"price"`
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Quantity    int    `json:"quantity"`
+	CodeValue   string `json:"code_value"`
+	IsPublished bool   `json:"is_published"`
+	Expiration  Date   `json:"expiration"`
+	Price       Money  `json:"price"`
+	// Version is maintained by the repository: 1 on creation, incremented by every write.
+	Version int `json:"version"`
+}
+
+// ETag returns the strong entity tag of the current version of p, quoted as in an ETag header.
+func (p Product) ETag() string {
+	return strconv.Quote(strconv.Itoa(p.Version))
 }
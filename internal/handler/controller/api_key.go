@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/go-chi/chi/v5"
+)
+
+type APIKeyController interface {
+	GetAPIKeys() http.HandlerFunc
+	IssueAPIKey() http.HandlerFunc
+	RotateAPIKey() http.HandlerFunc
+	RevokeAPIKey() http.HandlerFunc
+}
+
+type apiKeyController struct {
+	service service.ServiceAPIKey
+}
+
+// apiKeyResponse describes key, with secret when it was just issued or rotated.
+func apiKeyResponse(key domain.APIKey, secret string) utility.APIKeyResponse {
+	return utility.APIKeyResponse{
+		Id:        key.Id,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RotatedAt: key.RotatedAt,
+		ExpiresAt: key.ExpiresAt,
+		RevokedAt: key.RevokedAt,
+		Key:       secret,
+	}
+}
+
+func (kc *apiKeyController) GetAPIKeys() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		keys, err := kc.service.GetAPIKeys()
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		data := make([]utility.APIKeyResponse, 0, len(keys))
+		for _, key := range keys {
+			data = append(data, apiKeyResponse(key, ""))
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(data))
+	}
+}
+
+// IssueAPIKey creates a key. The response is the only one holding its secret.
+func (kc *apiKeyController) IssueAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody utility.APIKeyRequest
+		if err := decodeRequestBody(r, &reqBody); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		key, secret, err := kc.service.IssueAPIKey(reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		response := utility.NewSuccessResponse(apiKeyResponse(key, secret))
+		response.Code = http.StatusCreated
+		HandleResponse(w, response)
+	}
+}
+
+// RotateAPIKey gives a key a new secret, returned in the response, and disables the old one.
+func (kc *apiKeyController) RotateAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		key, secret, err := kc.service.RotateAPIKey(chi.URLParam(r, "id"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(apiKeyResponse(key, secret)))
+	}
+}
+
+func (kc *apiKeyController) RevokeAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		key, err := kc.service.RevokeAPIKey(chi.URLParam(r, "id"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(apiKeyResponse(key, "")))
+	}
+}
+
+func NewAPIKeyController(service service.ServiceAPIKey) *apiKeyController {
+	return &apiKeyController{
+		service: service,
+	}
+}
@@ -0,0 +1,172 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/handler/controller"
+	"github.com/MDavidCV/go-web-module/internal/handler/middleware"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeyService returns an API key service whose default key has the secret token.
+func newKeyService(t *testing.T, token string) service.ServiceAPIKey {
+	keyRepository, err := repository.NewRepositoryAPIKey(nil, nil)
+	require.NoError(t, err)
+
+	keys := service.NewServiceAPIKey(keyRepository)
+	require.NoError(t, keys.BootstrapAPIKey(token))
+
+	return keys
+}
+
+func TestAPIKeys(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	newRouter := func(t *testing.T, clock *time.Time) http.Handler {
+		keyRepository, err := repository.NewRepositoryAPIKey(nil, nil)
+		require.NoError(t, err)
+		keys := service.NewServiceAPIKey(keyRepository).WithClock(func() time.Time { return *clock })
+		require.NoError(t, keys.BootstrapAPIKey("secret"))
+		controller := controller.NewAPIKeyController(keys)
+
+		router := chi.NewRouter()
+		router.Route("/admin/api-keys", func(r chi.Router) {
+			r.Use(middleware.AuthValidationMid(keys))
+			r.Use(middleware.RequireScopeMid(auth.DefaultPolicy(), auth.ScopeAPIKeysAdmin))
+			r.Get("/", controller.GetAPIKeys())
+			r.Post("/", controller.IssueAPIKey())
+			r.Post("/{id}/rotate", controller.RotateAPIKey())
+			r.Delete("/{id}", controller.RevokeAPIKey())
+		})
+		return router
+	}
+
+	serve := func(router http.Handler, method, target, token, body string) (*httptest.ResponseRecorder, utility.APIKeyResponse) {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("token", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		var response struct {
+			Body utility.APIKeyResponse `json:"body"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response.Body
+	}
+
+	t.Run("sucess should issue a key whose secret is returned once and never stored", func(t *testing.T) {
+		// Arrange
+		router := newRouter(t, &now)
+
+		// Act
+		w, issued := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "ci", "scopes": ["apikeys:admin"]}`)
+		listed := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/admin/api-keys", nil)
+		r.Header.Set("token", issued.Key)
+		router.ServeHTTP(listed, r)
+
+		// Assert
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.True(t, strings.HasPrefix(issued.Key, issued.Id+"."))
+		require.Equal(t, []string{"apikeys:admin"}, issued.Scopes)
+
+		require.Equal(t, http.StatusOK, listed.Code)
+		require.NotContains(t, listed.Body.String(), issued.Key)
+		require.NotContains(t, listed.Body.String(), "hash")
+		require.Contains(t, listed.Body.String(), `"id":"default"`)
+	})
+
+	t.Run("should only grant the scopes of the key", func(t *testing.T) {
+		// Arrange
+		router := newRouter(t, &now)
+		_, issued := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "reader", "scopes": ["catalog:read"]}`)
+
+		// Act
+		w, _ := serve(router, "GET", "/admin/api-keys", issued.Key, "")
+
+		// Assert
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("sucess should disable the old secret when rotating a key", func(t *testing.T) {
+		// Arrange
+		router := newRouter(t, &now)
+		_, issued := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "ci", "scopes": ["apikeys:admin"]}`)
+
+		// Act
+		w, rotated := serve(router, "POST", "/admin/api-keys/"+issued.Id+"/rotate", "secret", "")
+		old, _ := serve(router, "GET", "/admin/api-keys", issued.Key, "")
+		current, _ := serve(router, "GET", "/admin/api-keys", rotated.Key, "")
+
+		// Assert
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, issued.Id, rotated.Id)
+		require.Equal(t, http.StatusUnauthorized, old.Code)
+		require.Equal(t, http.StatusOK, current.Code)
+	})
+
+	t.Run("should not store a default key when no token is configured", func(t *testing.T) {
+		// Arrange
+		keys := newKeyService(t, "")
+		router := chi.NewRouter()
+		router.Use(middleware.AuthValidationMid(keys))
+		router.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+		// Act
+		w, _ := serve(router, "GET", "/", "12345", "")
+		stored, err := keys.GetAPIKeys()
+
+		// Assert
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.NoError(t, err)
+		require.Empty(t, stored)
+	})
+
+	t.Run("should reject revoked and expired keys", func(t *testing.T) {
+		// Arrange
+		clock := now
+		router := newRouter(t, &clock)
+		_, revoked := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "revoked", "scopes": ["apikeys:admin"]}`)
+		_, expiring := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "expiring", "scopes": ["apikeys:admin"], "expires_at": "2024-05-01T13:00:00Z"}`)
+
+		// Act
+		revoke, _ := serve(router, "DELETE", "/admin/api-keys/"+revoked.Id, "secret", "")
+		afterRevoke, _ := serve(router, "GET", "/admin/api-keys", revoked.Key, "")
+		beforeExpiry, _ := serve(router, "GET", "/admin/api-keys", expiring.Key, "")
+		clock = now.Add(2 * time.Hour)
+		afterExpiry, _ := serve(router, "GET", "/admin/api-keys", expiring.Key, "")
+
+		// Assert
+		require.Equal(t, http.StatusOK, revoke.Code)
+		require.Equal(t, http.StatusUnauthorized, afterRevoke.Code)
+		require.Equal(t, http.StatusOK, beforeExpiry.Code)
+		require.Equal(t, http.StatusUnauthorized, afterExpiry.Code)
+	})
+
+	t.Run("should validate the key to issue", func(t *testing.T) {
+		// Arrange
+		router := newRouter(t, &now)
+
+		// Act
+		w, _ := serve(router, "POST", "/admin/api-keys", "secret", `{"name": "", "scopes": ["catalog:list"], "expires_at": "2020-01-01T00:00:00Z"}`)
+
+		// Assert
+		expectedBody := `{"code":422,"error":"validation failed","body":[
+			{"field":"name","code":"required","message":"name is required"},
+			{"field":"scopes","code":"invalid","message":"\"catalog:list\" is not a known scope"},
+			{"field":"expires_at","code":"invalid","message":"expires_at must be in the future"}
+		]}`
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/go-chi/chi/v5"
+)
+
+type CartController interface {
+	CreateCart() http.HandlerFunc
+	GetCart() http.HandlerFunc
+	AddCartItem() http.HandlerFunc
+	RemoveCartItem() http.HandlerFunc
+	DeleteCart() http.HandlerFunc
+}
+
+// cartController serves the carts of the authenticated client, identified by its subject.
+type cartController struct {
+	service service.ServiceCart
+}
+
+func (cc *cartController) CreateCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody utility.CartRequest
+		if r.ContentLength != 0 {
+			if err := decodeRequestBody(r, &reqBody); err != nil {
+				HandleError(w, r, err)
+				return
+			}
+		}
+
+		cart, err := cc.service.CreateCart(auth.SubjectFromContext(r.Context()), reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		response := utility.NewSuccessResponse(cart)
+		response.Code = http.StatusCreated
+		HandleResponse(w, response)
+	}
+}
+
+func (cc *cartController) GetCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		cart, err := cc.service.GetCart(auth.SubjectFromContext(r.Context()), chi.URLParam(r, "id"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(cart))
+	}
+}
+
+func (cc *cartController) AddCartItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody utility.CartItemRequest
+		if err := decodeRequestBody(r, &reqBody); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		cart, err := cc.service.AddCartItem(auth.SubjectFromContext(r.Context()), chi.URLParam(r, "id"), reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(cart))
+	}
+}
+
+// RemoveCartItem removes the units given by the quantity parameter of a product, or the whole item without it.
+func (cc *cartController) RemoveCartItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		cart, err := cc.service.RemoveCartItem(auth.SubjectFromContext(r.Context()), chi.URLParam(r, "id"), chi.URLParam(r, "productId"), r.URL.Query())
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(cart))
+	}
+}
+
+func (cc *cartController) DeleteCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if err := cc.service.DeleteCart(auth.SubjectFromContext(r.Context()), chi.URLParam(r, "id")); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		response := utility.NewSuccessResponse(nil)
+		response.Code = http.StatusNoContent
+		HandleResponse(w, response)
+	}
+}
+
+func NewCartController(service service.ServiceCart) *cartController {
+	return &cartController{
+		service: service,
+	}
+}
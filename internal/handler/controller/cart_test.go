@@ -0,0 +1,218 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/handler/controller"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type cartBody struct {
+	Body struct {
+		Id    int               `json:"id"`
+		Items []domain.CartItem `json:"items"`
+		Quote struct {
+			Total domain.Money `json:"total"`
+		} `json:"quote"`
+		Unavailable []service.CartIssue `json:"unavailable"`
+	} `json:"body"`
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+func decodeCart(t *testing.T, w *httptest.ResponseRecorder) cartBody {
+	t.Helper()
+	var body cartBody
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func withCartItem(r *http.Request, id string, productId string) *http.Request {
+	r = withId(r, id)
+	chi.RouteContext(r.Context()).URLParams.Add("productId", productId)
+	return r
+}
+
+func TestCart(t *testing.T) {
+	t.Run("sucess should price the items added to a cart", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+
+		wCreate := httptest.NewRecorder()
+		controller.CreateCart()(wCreate, httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 1, "quantity": 1}]}`)))
+		require.Equal(t, http.StatusCreated, wCreate.Code)
+
+		// Act
+		w := httptest.NewRecorder()
+		r := withId(httptest.NewRequest("POST", "/carts/1/items", strings.NewReader(`{"product_id": 1, "quantity": 1}`)), "1")
+		controller.AddCartItem()(w, r)
+
+		// Assert
+		body := decodeCart(t, w)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, []domain.CartItem{{ProductId: 1, Quantity: 2}}, body.Body.Items)
+		require.Equal(t, domain.MoneyFromFloat(24.2, ""), body.Body.Quote.Total)
+	})
+
+	t.Run("should not add more units than in stock", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+
+		controller.CreateCart()(httptest.NewRecorder(), httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 2, "quantity": 1}]}`)))
+
+		// Act
+		w := httptest.NewRecorder()
+		r := withId(httptest.NewRequest("POST", "/carts/1/items", strings.NewReader(`{"product_id": 2, "quantity": 1}`)), "1")
+		controller.AddCartItem()(w, r)
+
+		wGet := httptest.NewRecorder()
+		controller.GetCart()(wGet, withId(httptest.NewRequest("GET", "/carts/1", nil), "1"))
+
+		// Assert
+		expectedBody := `{"body":null, "code": 409, "error": "insufficient stock: product 2 has 1 units"}`
+
+		require.Equal(t, http.StatusConflict, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+		require.Equal(t, []domain.CartItem{{ProductId: 2, Quantity: 1}}, decodeCart(t, wGet).Body.Items)
+	})
+
+	t.Run("should not add a product priced in another currency", func(t *testing.T) {
+		// Arrange
+		mockStorage := newOrderMockStorage()
+		mockStorage[3] = domain.Product{Id: 3, Name: "Product 3", Quantity: 5, CodeValue: "13579", IsPublished: true, Expiration: domain.MustParseDate("01/02/2023"), Price: domain.MoneyFromFloat(30.0, "EUR")}
+		mockRepository, err := repository.NewRepositoryProduct(mockStorage, nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+
+		controller.CreateCart()(httptest.NewRecorder(), httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 1, "quantity": 1}]}`)))
+
+		// Act
+		w := httptest.NewRecorder()
+		r := withId(httptest.NewRequest("POST", "/carts/1/items", strings.NewReader(`{"product_id": 3, "quantity": 1}`)), "1")
+		controller.AddCartItem()(w, r)
+
+		wGet := httptest.NewRecorder()
+		controller.GetCart()(wGet, withId(httptest.NewRequest("GET", "/carts/1", nil), "1"))
+
+		// Assert
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "products priced in different currencies")
+		require.Equal(t, http.StatusOK, wGet.Code)
+		require.Equal(t, []domain.CartItem{{ProductId: 1, Quantity: 1}}, decodeCart(t, wGet).Body.Items)
+	})
+
+	t.Run("sucess should remove units of an item", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+
+		controller.CreateCart()(httptest.NewRecorder(), httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 1, "quantity": 3}, {"product_id": 2, "quantity": 1}]}`)))
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.RemoveCartItem()(w, withCartItem(httptest.NewRequest("DELETE", "/carts/1/items/1?quantity=2", nil), "1", "1"))
+		wWhole := httptest.NewRecorder()
+		controller.RemoveCartItem()(wWhole, withCartItem(httptest.NewRequest("DELETE", "/carts/1/items/2", nil), "1", "2"))
+
+		// Assert
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, []domain.CartItem{{ProductId: 1, Quantity: 1}, {ProductId: 2, Quantity: 1}}, decodeCart(t, w).Body.Items)
+		require.Equal(t, http.StatusOK, wWhole.Code)
+		require.Equal(t, []domain.CartItem{{ProductId: 1, Quantity: 1}}, decodeCart(t, wWhole).Body.Items)
+	})
+
+	t.Run("should report items that stopped being available", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+
+		controller.CreateCart()(httptest.NewRecorder(), httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 1, "quantity": 1}, {"product_id": 2, "quantity": 1}]}`)))
+		require.NoError(t, mockRepository.DeleteProduct(2))
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.GetCart()(w, withId(httptest.NewRequest("GET", "/carts/1", nil), "1"))
+
+		// Assert
+		body := decodeCart(t, w)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Len(t, body.Body.Unavailable, 1)
+		require.Equal(t, 2, body.Body.Unavailable[0].ProductId)
+		require.Equal(t, "product not found", body.Body.Unavailable[0].Reason)
+		require.Equal(t, domain.MoneyFromFloat(12.1, ""), body.Body.Quote.Total)
+	})
+
+	t.Run("should hide a cart from the clients that did not create it", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(orderClock)
+		controller := controller.NewCartController(service)
+		as := func(r *http.Request, subject string) *http.Request {
+			return r.WithContext(auth.WithClaims(r.Context(), auth.Claims{Subject: subject}))
+		}
+
+		controller.CreateCart()(httptest.NewRecorder(), as(httptest.NewRequest("POST", "/carts", strings.NewReader(`{"items": [{"product_id": 1, "quantity": 1}]}`)), "alice"))
+
+		// Act
+		wGet := httptest.NewRecorder()
+		controller.GetCart()(wGet, as(withId(httptest.NewRequest("GET", "/carts/1", nil), "1"), "bob"))
+		wAdd := httptest.NewRecorder()
+		controller.AddCartItem()(wAdd, as(withId(httptest.NewRequest("POST", "/carts/1/items", strings.NewReader(`{"product_id": 1, "quantity": 1}`)), "1"), "bob"))
+		wDelete := httptest.NewRecorder()
+		controller.DeleteCart()(wDelete, as(withId(httptest.NewRequest("DELETE", "/carts/1", nil), "1"), "bob"))
+		wOwner := httptest.NewRecorder()
+		controller.GetCart()(wOwner, as(withId(httptest.NewRequest("GET", "/carts/1", nil), "1"), "alice"))
+
+		// Assert
+		require.Equal(t, http.StatusNotFound, wGet.Code)
+		require.Equal(t, http.StatusNotFound, wAdd.Code)
+		require.Equal(t, http.StatusNotFound, wDelete.Code)
+		require.Equal(t, http.StatusOK, wOwner.Code)
+		require.Equal(t, []domain.CartItem{{ProductId: 1, Quantity: 1}}, decodeCart(t, wOwner).Body.Items)
+	})
+
+	t.Run("should expire abandoned carts", func(t *testing.T) {
+		// Arrange
+		now := orderClock()
+		clock := func() time.Time { return now }
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceCart(repository.NewRepositoryCart(), mockRepository, nil, time.Hour).WithClock(clock)
+		controller := controller.NewCartController(service)
+
+		controller.CreateCart()(httptest.NewRecorder(), httptest.NewRequest("POST", "/carts", nil))
+		now = now.Add(time.Hour + time.Minute)
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.GetCart()(w, withId(httptest.NewRequest("GET", "/carts/1", nil), "1"))
+		deleted, err := service.ExpireCarts()
+
+		// Assert
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+	})
+}
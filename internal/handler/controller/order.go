@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/go-chi/chi/v5"
+)
+
+type OrderController interface {
+	CreateOrder() http.HandlerFunc
+	GetOrderById() http.HandlerFunc
+	CancelOrder() http.HandlerFunc
+}
+
+type orderController struct {
+	service service.ServiceOrder
+}
+
+func (oc *orderController) CreateOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody utility.OrderRequest
+		if err := decodeRequestBody(r, &reqBody); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		order, err := oc.service.CreateOrder(reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		response := utility.NewSuccessResponse(order)
+		response.Code = http.StatusCreated
+		HandleResponse(w, response)
+	}
+}
+
+func (oc *orderController) GetOrderById() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		order, err := oc.service.GetOrderById(chi.URLParam(r, "id"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(order))
+	}
+}
+
+// CancelOrder cancels the order and puts its products back in stock.
+func (oc *orderController) CancelOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		order, err := oc.service.CancelOrder(chi.URLParam(r, "id"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(order))
+	}
+}
+
+func NewOrderController(service service.ServiceOrder) *orderController {
+	return &orderController{
+		service: service,
+	}
+}
@@ -0,0 +1,186 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/handler/controller"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func newOrderMockStorage() map[int]domain.Product {
+	return map[int]domain.Product{
+		1: {Id: 1, Name: "Product 1", Quantity: 5, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/02/2023"), Price: domain.MoneyFromFloat(10.0, "")},
+		2: {Id: 2, Name: "Product 2", Quantity: 1, CodeValue: "67890", IsPublished: true, Expiration: domain.MustParseDate("01/02/2023"), Price: domain.MoneyFromFloat(20.0, "")},
+	}
+}
+
+func orderClock() time.Time {
+	return time.Date(2023, time.January, 10, 15, 0, 0, 0, time.UTC)
+}
+
+func withId(r *http.Request, id string) *http.Request {
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("id", id)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
+}
+
+// failingOrderStorage is a StorageOrder whose writes always fail.
+type failingOrderStorage struct{}
+
+func (failingOrderStorage) GetOrders() ([]domain.Order, error) { return nil, nil }
+
+func (failingOrderStorage) WriteOrders([]domain.Order) error {
+	return utility.NewStorageError(utility.ErrStorageUnavailable, "write orders", errors.New("disk full"))
+}
+
+func (failingOrderStorage) AppendOrder(domain.Order) error {
+	return utility.NewStorageError(utility.ErrStorageUnavailable, "append log", errors.New("disk full"))
+}
+
+func (failingOrderStorage) NeedsCompaction() bool { return false }
+
+func TestCreateOrder(t *testing.T) {
+	t.Run("sucess should place the order and take its products out of stock", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		mockOrders, err := repository.NewRepositoryOrder(nil, nil)
+		require.NoError(t, err)
+		service := service.NewServiceOrder(mockRepository, mockOrders, nil).WithClock(orderClock)
+		controller := controller.NewOrderController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"list": [1, 1]}`))
+		w := httptest.NewRecorder()
+		controller.CreateOrder()(w, r)
+
+		// Assert
+		expectedCode := http.StatusCreated
+		expectedBody := `{"body":{"id":1,"status":"placed","lines":[{"product_id":1,"quantity":2,"unit_price":10,"total":20}],"total":24.2,"created_at":"2023-01-10T15:00:00Z"}, "code": 201, "error": ""}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, 3, product.Quantity)
+	})
+
+	t.Run("should not take any stock when a product has not enough units", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		mockOrders, err := repository.NewRepositoryOrder(nil, nil)
+		require.NoError(t, err)
+		service := service.NewServiceOrder(mockRepository, mockOrders, nil).WithClock(orderClock)
+		controller := controller.NewOrderController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"list": [1, 2, 2]}`))
+		w := httptest.NewRecorder()
+		controller.CreateOrder()(w, r)
+
+		// Assert
+		expectedCode := http.StatusConflict
+		expectedBody := `{"body":null, "code": 409, "error": "insufficient stock: product 2 has 1 units"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, 5, product.Quantity)
+	})
+
+	t.Run("should give the stock back when the order cannot be stored", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		mockOrders, err := repository.NewRepositoryOrder(nil, failingOrderStorage{})
+		require.NoError(t, err)
+		service := service.NewServiceOrder(mockRepository, mockOrders, nil).WithClock(orderClock)
+		controller := controller.NewOrderController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"list": [1, 1]}`))
+		w := httptest.NewRecorder()
+		controller.CreateOrder()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, 5, product.Quantity)
+
+		_, err = mockOrders.GetOrderById(1)
+		require.ErrorIs(t, err, utility.ErrOrderNotFound)
+	})
+}
+
+func TestCancelOrder(t *testing.T) {
+	t.Run("sucess should cancel the order and restock its products once", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		mockOrders, err := repository.NewRepositoryOrder(nil, nil)
+		require.NoError(t, err)
+		service := service.NewServiceOrder(mockRepository, mockOrders, nil).WithClock(orderClock)
+		controller := controller.NewOrderController(service)
+
+		r := httptest.NewRequest("POST", "/orders", strings.NewReader(`{"list": [1, 1, 2]}`))
+		controller.CreateOrder()(httptest.NewRecorder(), r)
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.CancelOrder()(w, withId(httptest.NewRequest("POST", "/orders/1/cancel", nil), "1"))
+		wAgain := httptest.NewRecorder()
+		controller.CancelOrder()(wAgain, withId(httptest.NewRequest("POST", "/orders/1/cancel", nil), "1"))
+
+		// Assert
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), `"status":"cancelled"`)
+		require.Equal(t, http.StatusConflict, wAgain.Code)
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, 5, product.Quantity)
+		product, err = mockRepository.GetProductById(2)
+		require.NoError(t, err)
+		require.Equal(t, 1, product.Quantity)
+	})
+}
+
+func TestGetUnexistentOrderById(t *testing.T) {
+	t.Run("should return an error when the order does not exist", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newOrderMockStorage(), nil)
+		require.NoError(t, err)
+		mockOrders, err := repository.NewRepositoryOrder(nil, nil)
+		require.NoError(t, err)
+		service := service.NewServiceOrder(mockRepository, mockOrders, nil)
+		controller := controller.NewOrderController(service)
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.GetOrderById()(w, withId(httptest.NewRequest("GET", "/orders/7", nil), "7"))
+
+		// Assert
+		expectedCode := http.StatusNotFound
+		expectedBody := `{"body":null, "code": 404, "error": "order not found"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+}
@@ -2,10 +2,22 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/catalog"
 	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/patch"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
 	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/internal/validation"
 	"github.com/MDavidCV/go-web-module/utility"
 	"github.com/go-chi/chi/v5"
 )
@@ -23,27 +35,150 @@ type productController struct {
 	service service.ServiceProduct
 }
 
+// GetProducts lists a page of the catalog, or the whole catalog streamed as NDJSON or as a JSON array
+// when the stream query parameter is given.
 func (pc *productController) GetProducts() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		products, err := pc.service.GetProducts()
+		if r.URL.Query().Has("stream") {
+			pc.streamProducts(w, r)
+			return
+		}
+
+		page, err := pc.service.GetProducts(r.URL.Query())
 
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
-		HandleResponse(w, utility.NewSuccessResponse(products))
+		data, err := selectFields(page.Products, page.Fields)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewPageResponse(data, pageMeta(r, page)))
+	}
+}
+
+// streamProducts writes every product as it is read from the repository, so the catalog is never held in memory.
+func (pc *productController) streamProducts(w http.ResponseWriter, r *http.Request) {
+	stream, err := pc.service.StreamProducts(r.URL.Query())
+	if err != nil {
+		HandleError(w, r, err)
+		return
+	}
+
+	writeStream(w, r, stream)
+}
+
+func writeStream(w http.ResponseWriter, r *http.Request, stream service.ProductStream) {
+	w.Header().Set("Content-Type", stream.Format.MediaType())
+	w.WriteHeader(http.StatusOK)
+
+	// The status is sent already: a failure, such as the client going away, can only cut the list short.
+	writer := catalog.NewWriter(w, stream.Format)
+	err := stream.Each(func(product domain.Product) error {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		return writer.Write(product)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		log.Printf("unable to stream products: %v", err)
+	}
+}
+
+// pageMeta describes page, linking to its neighbours with the same kind of pagination as r.
+func pageMeta(r *http.Request, page service.ProductPage) utility.PageMeta {
+	meta := utility.PageMeta{
+		Total:      page.Total,
+		Count:      len(page.Products),
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+
+	link := func(param, value string) string {
+		query := r.URL.Query()
+		query.Del("cursor")
+		query.Del("offset")
+		query.Set(param, value)
+		return r.URL.Path + "?" + query.Encode()
+	}
+
+	if r.URL.Query().Has("cursor") {
+		if page.NextCursor != "" {
+			meta.Next = link("cursor", page.NextCursor)
+		}
+		if page.PrevCursor != "" {
+			meta.Prev = link("cursor", page.PrevCursor)
+		}
+		return meta
+	}
+
+	if page.NextCursor != "" {
+		meta.Next = link("offset", strconv.Itoa(page.Offset+len(page.Products)))
+	}
+	if page.Offset > 0 {
+		prevOffset := 0
+		if page.Limit > 0 {
+			prevOffset = max(page.Offset-page.Limit, 0)
+		}
+		meta.Prev = link("offset", strconv.Itoa(prevOffset))
+	}
+
+	return meta
+}
+
+// selectFields returns products with only the given json fields, or products unchanged when fields is empty.
+func selectFields(products []domain.Product, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return products, nil
+	}
+
+	selected := make([]map[string]json.RawMessage, 0, len(products))
+	for _, product := range products {
+		data, err := json.Marshal(product)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+
+		item := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			item[field] = all[field]
+		}
+		selected = append(selected, item)
 	}
+
+	return selected, nil
 }
 
+// GetProductById answers with 304 Not Modified, without a body, when the If-None-Match header of the request
+// matches the ETag of the product.
 func (pc *productController) GetProductById() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		product, err := pc.service.GetProductById(chi.URLParam(r, "id"))
 
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", product.ETag())
+		if service.NotModified(r.Header.Get("If-None-Match"), product) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 
@@ -51,13 +186,14 @@ func (pc *productController) GetProductById() http.HandlerFunc {
 	}
 }
 
+// SearchProduct returns the products matching every filter of the query, or any of them with op=or.
 func (pc *productController) SearchProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		productsFiltered, err := pc.service.SearchProduct(r.URL.Query().Get("priceGt"))
+		productsFiltered, err := pc.service.SearchProduct(r.URL.Query())
 
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
@@ -68,20 +204,25 @@ func (pc *productController) SearchProduct() http.HandlerFunc {
 func (pc *productController) CreateProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		var reqBody utility.ProductRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			err = utility.ErrInvalidRequestBody
-			HandleResponse(w, utility.NewErrorResponse(err))
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			HandleError(w, r, utility.ErrInvalidRequestBody)
+			return
+		}
+		reqBody, err := validation.DecodeProductRequest(body)
+		if err != nil {
+			HandleError(w, r, err)
 			return
 		}
 
 		product, err := pc.service.CreateProduct(reqBody)
 
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
+		w.Header().Set("ETag", product.ETag())
 		response := utility.NewSuccessResponse(product)
 		response.Code = http.StatusCreated
 		HandleResponse(w, response)
@@ -91,28 +232,33 @@ func (pc *productController) CreateProduct() http.HandlerFunc {
 func (pc *productController) UpdateProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		var reqBody utility.ProductRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			err = utility.ErrInvalidRequestBody
-			HandleResponse(w, utility.NewErrorResponse(err))
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			HandleError(w, r, utility.ErrInvalidRequestBody)
+			return
+		}
+		reqBody, err := validation.DecodeProductRequest(body)
+		if err != nil {
+			HandleError(w, r, err)
 			return
 		}
 
-		product, err := pc.service.UpdateProduct(chi.URLParam(r, "id"), reqBody)
+		product, err := pc.service.UpdateProduct(chi.URLParam(r, "id"), reqBody, r.Header.Get("If-Match"))
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
+		w.Header().Set("ETag", product.ETag())
 		HandleResponse(w, utility.NewSuccessResponse(product))
 	}
 }
 func (pc *productController) DeleteProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		err := pc.service.DeleteProduct(chi.URLParam(r, "id"))
+		err := pc.service.DeleteProduct(chi.URLParam(r, "id"), r.Header.Get("If-Match"))
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
@@ -122,50 +268,311 @@ func (pc *productController) DeleteProduct() http.HandlerFunc {
 	}
 }
 
+// UpdatePatchProduct applies a JSON Merge Patch or a JSON Patch, as given by the Content-Type of the request,
+// or a utility.ProductPatchRequest for plain JSON.
 func (pc *productController) UpdatePatchProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		var reqBody utility.ProductPatchRequest
-		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-			err = utility.ErrInvalidRequestBody
-			HandleResponse(w, utility.NewErrorResponse(err))
+		contentType := "application/json"
+		if value := r.Header.Get("Content-Type"); value != "" {
+			var err error
+			if contentType, _, err = mime.ParseMediaType(value); err != nil {
+				HandleError(w, r, utility.ErrUnsupportedMediaType)
+				return
+			}
+		}
+
+		if contentType != "application/json" {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				HandleError(w, r, utility.ErrInvalidRequestBody)
+				return
+			}
+
+			p, err := patch.New(contentType, body)
+			if err != nil {
+				HandleError(w, r, err)
+				return
+			}
+
+			product, err := pc.service.ApplyProductPatch(chi.URLParam(r, "id"), p, r.Header.Get("If-Match"))
+			if err != nil {
+				HandleError(w, r, err)
+				return
+			}
+
+			w.Header().Set("ETag", product.ETag())
+			HandleResponse(w, utility.NewSuccessResponse(product))
 			return
 		}
 
-		product, err := pc.service.UpdatePatchProduct(chi.URLParam(r, "id"), reqBody)
+		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, utility.ErrInvalidRequestBody)
+			return
+		}
+		reqBody, err := validation.DecodeProductPatchRequest(body)
+		if err != nil {
+			HandleError(w, r, err)
 			return
 		}
 
+		product, err := pc.service.UpdatePatchProduct(chi.URLParam(r, "id"), reqBody, r.Header.Get("If-Match"))
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", product.ETag())
 		HandleResponse(w, utility.NewSuccessResponse(product))
 	}
 }
+
+// CreateProducts creates the array of products of the request body in a single batch.
+func (pc *productController) CreateProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody []utility.ProductRequest
+		if err := decodeRequestBody(r, &reqBody); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		result, err := pc.service.CreateProducts(r.URL.Query(), reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, bulkResponse(result, http.StatusCreated))
+	}
+}
+
+// PatchProducts patches or deletes the products of the array of items of the request body in a single batch.
+func (pc *productController) PatchProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var reqBody []utility.ProductBulkPatchItem
+		if err := decodeRequestBody(r, &reqBody); err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		// The route requires catalog:write, deletes also need catalog:delete.
+		for _, item := range reqBody {
+			if item.Delete && !auth.HasScope(r.Context(), auth.ScopeCatalogDelete) {
+				HandleError(w, r, fmt.Errorf("%w: %s is required", utility.ErrForbidden, auth.ScopeCatalogDelete))
+				return
+			}
+		}
+
+		result, err := pc.service.PatchProducts(r.URL.Query(), reqBody)
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, bulkResponse(result, http.StatusOK))
+	}
+}
+
+// bulkResponse reports result with code when every item was applied, 207 Multi-Status when a best effort batch
+// was partly applied, and the status of the first failure when an atomic batch was rolled back.
+func bulkResponse(result service.BulkResult, code int) utility.Response {
+	report := utility.BulkReport{
+		Mode:      string(result.Mode),
+		Failed:    result.Failed(),
+		Succeeded: len(result.Items) - result.Failed(),
+		Results:   bulkResults(result, code),
+	}
+
+	return bulkStatus(utility.NewSuccessResponse(report), result, code)
+}
+
+// bulkResults returns the response of each item of result, with code for the items that returned a product.
+// Items that were applied without returning a product, i.e. deleted, are reported as 204 No Content.
+func bulkResults(result service.BulkResult, code int) []utility.Response {
+	results := make([]utility.Response, 0, len(result.Items))
+	for _, item := range result.Items {
+		switch {
+		case item.Err != nil:
+			results = append(results, utility.NewErrorResponse(item.Err))
+		case item.Product == nil:
+			results = append(results, utility.Response{Code: http.StatusNoContent})
+		default:
+			itemResponse := utility.NewSuccessResponse(item.Product)
+			itemResponse.Code = code
+			results = append(results, itemResponse)
+		}
+	}
+	return results
+}
+
+func bulkStatus(response utility.Response, result service.BulkResult, code int) utility.Response {
+	switch {
+	case result.Failed() == 0:
+		response.Code = code
+	case result.Mode == service.BulkBestEffort:
+		response.Code = http.StatusMultiStatus
+	default:
+		errResponse := utility.NewErrorResponse(result.FirstError())
+		response.Code = errResponse.Code
+		response.Error = errResponse.Error
+	}
+	return response
+}
+
+// ExportProducts streams the whole catalog as CSV, NDJSON or a JSON array, as given by the format query parameter.
+func (pc *productController) ExportProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		stream, err := pc.service.ExportProducts(r.URL.Query())
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, stream.Format))
+		writeStream(w, r, stream)
+	}
+}
+
+// maxImportBytes bounds the size of an upload, which is read whole before being applied.
+const maxImportBytes = 16 << 20
+
+// ImportProducts creates the products of a CSV or NDJSON upload, as given by its Content-Type, and reports
+// the outcome of every row like a bulk request.
+func (pc *productController) ImportProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			HandleError(w, r, utility.ErrUnsupportedMediaType)
+			return
+		}
+
+		result, err := pc.service.ImportProducts(r.URL.Query(), mediaType, r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = fmt.Errorf("%w: at most %d bytes", utility.ErrRequestTooLarge, tooLarge.Limit)
+		}
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		report := utility.ImportReport{
+			Format:    string(result.Format),
+			Mode:      string(result.Mode),
+			Failed:    result.Failed(),
+			Succeeded: len(result.Items) - result.Failed(),
+		}
+		for i, itemResponse := range bulkResults(result.BulkResult, http.StatusCreated) {
+			report.Results = append(report.Results, utility.ImportRowResult{Line: result.Lines[i], Response: itemResponse})
+		}
+
+		HandleResponse(w, bulkStatus(utility.NewSuccessResponse(report), result.BulkResult, http.StatusCreated))
+	}
+}
+
 func (pc *productController) GetConsumerPrice() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("list")
-		products, totalPrice, err := pc.service.GetConsumerPrice(query)
+		products, quote, err := pc.service.GetConsumerPrice(query)
 
 		if err != nil {
-			HandleResponse(w, utility.NewErrorResponse(err))
+			HandleError(w, r, err)
 			return
 		}
 
 		data := struct {
 			Products   []domain.Product
-			TotalPrice float64
-		}{Products: products, TotalPrice: totalPrice}
+			TotalPrice domain.Money
+			Breakdown  pricing.Quote
+		}{Products: products, TotalPrice: quote.Total, Breakdown: quote}
 		HandleResponse(w, utility.NewSuccessResponse(data))
 	}
 }
 
+// GetExpiringProducts reports the products that expire within the window of the within parameter, e.g. "7d".
+func (pc *productController) GetExpiringProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		products, err := pc.service.GetExpiringProducts(r.URL.Query())
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(products))
+	}
+}
+
+// GetExpiredProducts reports the products whose expiration date has passed.
+func (pc *productController) GetExpiredProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		products, err := pc.service.GetExpiredProducts()
+		if err != nil {
+			HandleError(w, r, err)
+			return
+		}
+
+		HandleResponse(w, utility.NewSuccessResponse(products))
+	}
+}
+
 func NewProductController(service service.ServiceProduct) *productController {
 	return &productController{
 		service: service,
 	}
 }
 
+// decodeRequestBody decodes the JSON body of r into v, reporting malformed dates as utility.ErrInvalidDate.
+func decodeRequestBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if errors.Is(err, domain.ErrInvalidDate) {
+			return utility.ErrInvalidDate
+		}
+		return utility.ErrInvalidRequestBody
+	}
+	return nil
+}
+
+// acceptsProblem reports whether the client asked for application/problem+json errors in its Accept header.
+func acceptsProblem(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && mediaType == utility.ProblemContentType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandleError writes err as an RFC 7807 problem when the client accepts application/problem+json,
+// and in the utility.Response envelope otherwise.
+func HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	if !acceptsProblem(r) {
+		HandleResponse(w, utility.NewErrorResponse(err))
+		return
+	}
+
+	problem := utility.NewProblem(err, r.URL.RequestURI(), utility.RequestIdFromContext(r.Context()))
+	w.Header().Set("Content-Type", utility.ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
 func HandleResponse(w http.ResponseWriter, response utility.Response) {
+	// An unset code would make WriteHeader panic.
+	if response.Code == 0 {
+		response.Code = http.StatusInternalServerError
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(response.Code)
 	json.NewEncoder(w).Encode(response)
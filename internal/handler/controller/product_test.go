@@ -2,16 +2,26 @@ package controller_test
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/MDavidCV/go-web-module/internal/auth"
 	"github.com/MDavidCV/go-web-module/internal/domain"
 	"github.com/MDavidCV/go-web-module/internal/handler/controller"
 	"github.com/MDavidCV/go-web-module/internal/handler/middleware"
 	"github.com/MDavidCV/go-web-module/internal/repository"
 	"github.com/MDavidCV/go-web-module/internal/service"
+	"github.com/MDavidCV/go-web-module/utility"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/require"
 )
@@ -28,8 +38,8 @@ func TestProductsGet(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 			2: {
 				Id:          2,
@@ -37,12 +47,13 @@ func TestProductsGet(t *testing.T) {
 				Quantity:    20,
 				CodeValue:   "67890",
 				IsPublished: false,
-				Expiration:  "2023-01-02",
-				Price:       200.0,
+				Expiration:  domain.MustParseDate("2023-01-02"),
+				Price:       domain.MoneyFromFloat(200.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
@@ -52,13 +63,130 @@ func TestProductsGet(t *testing.T) {
 
 		// Assert
 		expectedCode := http.StatusOK
-		expectedBody := `{"body":[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"2023-01-01","price":100},{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":false,"expiration":"2023-01-02","price":200}], "code": 200, "error": ""}`
+		expectedBody := `{"body":[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":0},{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":false,"expiration":"02/01/2023","price":200,"version":0}], "code": 200, "error": "", "meta": {"total": 2, "count": 2, "offset": 0}}`
 		expectedHeader := http.Header{"Content-Type": []string{"application/json"}}
 
 		require.Equal(t, expectedCode, w.Code)
 		require.JSONEq(t, expectedBody, w.Body.String())
 		require.Equal(t, expectedHeader, w.Header())
 	})
+
+	t.Run("sucess should stream the products as a json array", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: false, Expiration: domain.MustParseDate("2023-01-02"), Price: domain.MoneyFromFloat(200.0, "")},
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products?stream=json", nil)
+		w := httptest.NewRecorder()
+		controller.GetProducts()(w, r)
+
+		// Assert
+		expectedBody := `[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":0},{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":false,"expiration":"02/01/2023","price":200,"version":0}]`
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should reject a stream combined with pagination", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products?stream=ndjson&limit=10", nil)
+		w := httptest.NewRecorder()
+		controller.GetProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"body":null, "code": 400, "error": "invalid query: limit: cannot be combined with stream"}`
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should return a sorted page with the selected fields", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: false, Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200.0, "")},
+			3: {Id: 3, Name: "Product 3", Quantity: 30, CodeValue: "13579", IsPublished: true, Expiration: domain.MustParseDate("03/01/2023"), Price: domain.MoneyFromFloat(200.0, "")},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products?sort=-price,name&fields=id,price&limit=2&offset=1", nil)
+		w := httptest.NewRecorder()
+		controller.GetProducts()(w, r)
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":[{"id":3,"price":200},{"id":1,"price":100}], "code": 200, "error": "", "meta": {"total": 3, "count": 2, "limit": 2, "offset": 1, "prev_cursor": "%s", "prev": "/products?fields=id%%2Cprice&limit=2&offset=0&sort=-price%%2Cname"}}`
+
+		require.Equal(t, expectedCode, w.Code)
+		var response struct {
+			Meta struct {
+				PrevCursor string `json:"prev_cursor"`
+			} `json:"meta"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.JSONEq(t, fmt.Sprintf(expectedBody, response.Meta.PrevCursor), w.Body.String())
+	})
+
+	t.Run("sucess should walk the catalog with cursors", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: false, Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200.0, "")},
+			3: {Id: 3, Name: "Product 3", Quantity: 30, CodeValue: "13579", IsPublished: true, Expiration: domain.MustParseDate("03/01/2023"), Price: domain.MoneyFromFloat(300.0, "")},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		type page struct {
+			Body []domain.Product `json:"body"`
+			Meta struct {
+				Next       string `json:"next"`
+				Prev       string `json:"prev"`
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		get := func(target string) page {
+			w := httptest.NewRecorder()
+			controller.GetProducts()(w, httptest.NewRequest("GET", target, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var p page
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			return p
+		}
+
+		// Act
+		first := get("/products?limit=2")
+		second := get("/products?limit=2&cursor=" + first.Meta.NextCursor)
+		back := get(second.Meta.Prev)
+
+		// Assert
+		require.Equal(t, []int{1, 2}, []int{first.Body[0].Id, first.Body[1].Id})
+		require.Len(t, second.Body, 1)
+		require.Equal(t, 3, second.Body[0].Id)
+		require.Empty(t, second.Meta.Next)
+		require.Equal(t, first.Body, back.Body)
+	})
 }
 
 func TestProuductByIdGet(t *testing.T) {
@@ -71,8 +199,8 @@ func TestProuductByIdGet(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 			2: {
 				Id:          2,
@@ -80,12 +208,13 @@ func TestProuductByIdGet(t *testing.T) {
 				Quantity:    20,
 				CodeValue:   "67890",
 				IsPublished: false,
-				Expiration:  "2023-01-02",
-				Price:       200.0,
+				Expiration:  domain.MustParseDate("2023-01-02"),
+				Price:       domain.MoneyFromFloat(200.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
@@ -100,21 +229,307 @@ func TestProuductByIdGet(t *testing.T) {
 
 		// Assert
 		expectedCode := http.StatusOK
-		expectedBody := `{"body":{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"2023-01-01","price":100}, "code": 200, "error": ""}`
+		expectedBody := `{"body":{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":0}, "code": 200, "error": ""}`
+		expectedHeader := http.Header{"Content-Type": []string{"application/json"}, "Etag": []string{`"0"`}}
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+		require.Equal(t, expectedHeader, w.Header())
+	})
+}
+
+func TestSearchProduct(t *testing.T) {
+	t.Run("sucess should return the products matching every filter", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {
+				Id:          1,
+				Name:        "Product 1",
+				Quantity:    10,
+				CodeValue:   "12345",
+				IsPublished: true,
+				Expiration:  domain.MustParseDate("01/01/2023"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
+			},
+			2: {
+				Id:          2,
+				Name:        "Product 2",
+				Quantity:    20,
+				CodeValue:   "67890",
+				IsPublished: false,
+				Expiration:  domain.MustParseDate("02/01/2023"),
+				Price:       domain.MoneyFromFloat(200.0, ""),
+			},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/search?priceBetween=50,250&isPublished=false&namePrefix=product", nil)
+		w := httptest.NewRecorder()
+		controller.SearchProduct()(w, r)
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":[{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":false,"expiration":"02/01/2023","price":200,"version":0}], "code": 200, "error": ""}`
 		expectedHeader := http.Header{"Content-Type": []string{"application/json"}}
 
 		require.Equal(t, expectedCode, w.Code)
 		require.JSONEq(t, expectedBody, w.Body.String())
 		require.Equal(t, expectedHeader, w.Header())
 	})
+
+	t.Run("sucess should return the products matching any filter", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {
+				Id:          1,
+				Name:        "Product 1",
+				Quantity:    10,
+				CodeValue:   "12345",
+				IsPublished: true,
+				Expiration:  domain.MustParseDate("01/01/2023"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
+			},
+			2: {
+				Id:          2,
+				Name:        "Product 2",
+				Quantity:    20,
+				CodeValue:   "67890",
+				IsPublished: false,
+				Expiration:  domain.MustParseDate("02/01/2023"),
+				Price:       domain.MoneyFromFloat(200.0, ""),
+			},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/search?op=or&quantityLt=5&expirationBefore=02/01/2023", nil)
+		w := httptest.NewRecorder()
+		controller.SearchProduct()(w, r)
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":0}], "code": 200, "error": ""}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should reject more than one op", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/search?op=and&quantityLt=5&op=or&expirationBefore=02/01/2023", nil)
+		w := httptest.NewRecorder()
+		controller.SearchProduct()(w, r)
+
+		// Assert
+		expectedBody := `{"body":null, "code": 400, "error": "invalid query: op: expected a single value"}`
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should only match prices in the currency of the bounds", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: true, Price: domain.MoneyFromFloat(150.0, "EUR")},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/search?priceBetween=50%20EUR,250%20EUR", nil)
+		w := httptest.NewRecorder()
+		controller.SearchProduct()(w, r)
+
+		// Assert
+		expectedBody := `{"body":[{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":true,"expiration":"","price":{"amount":150,"currency":"EUR"},"version":0}], "code": 200, "error": ""}`
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+}
+
+func TestExpiryReports(t *testing.T) {
+	newMockStorage := func() map[int]domain.Product {
+		return map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("09/01/2023"), Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: true, Expiration: domain.MustParseDate("10/01/2023"), Price: domain.MoneyFromFloat(200.0, "")},
+			3: {Id: 3, Name: "Product 3", Quantity: 30, CodeValue: "13579", IsPublished: true, Expiration: domain.MustParseDate("20/01/2023"), Price: domain.MoneyFromFloat(300.0, "")},
+		}
+	}
+	today := func() time.Time { return time.Date(2023, time.January, 10, 15, 0, 0, 0, time.UTC) }
+
+	t.Run("sucess should return the products expiring within the window", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/expiring?within=7d", nil)
+		w := httptest.NewRecorder()
+		controller.GetExpiringProducts()(w, r)
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":[{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":true,"expiration":"10/01/2023","price":200,"version":0}], "code": 200, "error": ""}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should return the expired products", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/expired", nil)
+		w := httptest.NewRecorder()
+		controller.GetExpiredProducts()(w, r)
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"09/01/2023","price":100,"version":0}], "code": 200, "error": ""}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should unpublish the expired products", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+
+		// Act
+		unpublished, err := service.UnpublishExpiredProducts()
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		require.Equal(t, 1, unpublished[0].Id)
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.False(t, product.IsPublished)
+		product, err = mockRepository.GetProductById(2)
+		require.NoError(t, err)
+		require.True(t, product.IsPublished)
+	})
+
+	t.Run("sucess should leave products without an expiration published", func(t *testing.T) {
+		// Arrange
+		mockSt := newMockStorage()
+		mockSt[3] = domain.Product{Id: 3, Name: "Product 3", Quantity: 30, CodeValue: "13579", IsPublished: true, Price: domain.MoneyFromFloat(300.0, "")}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		productService := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// Act
+		service.NewExpiryScheduler(productService, time.Hour).Run(ctx)
+		expiring, err := productService.GetExpiringProducts(url.Values{"within": {"365d"}})
+		require.NoError(t, err)
+
+		// Assert
+		product, err := mockRepository.GetProductById(3)
+		require.NoError(t, err)
+		require.True(t, product.IsPublished)
+		product, err = mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.False(t, product.IsPublished)
+		for _, product := range expiring {
+			require.NotEqual(t, 3, product.Id)
+		}
+	})
+
+	t.Run("should not price expired products", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/consumer_price?list=[1,2]", nil)
+		w := httptest.NewRecorder()
+		controller.GetConsumerPrice()(w, r)
+
+		// Assert
+		expectedCode := http.StatusBadRequest
+		expectedBody := `{"body":null, "code": 400, "error": "product expired: product 1"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should answer unpublished and out of stock products as an invalid query", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: false, Expiration: domain.MustParseDate("01/02/2023"), Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 1, CodeValue: "67890", IsPublished: true, Expiration: domain.MustParseDate("01/02/2023"), Price: domain.MoneyFromFloat(200.0, "")},
+		}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+		controller := controller.NewProductController(service)
+
+		for _, list := range []string{"[1]", "[2,2]"} {
+			// Act
+			r := httptest.NewRequest("GET", "/products/consumer_price?list="+list, nil)
+			w := httptest.NewRecorder()
+			controller.GetConsumerPrice()(w, r)
+
+			// Assert
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.JSONEq(t, `{"body":null, "code": 400, "error": "invalid query"}`, w.Body.String())
+		}
+	})
+
+	t.Run("should return an error when the window is invalid", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil).WithClock(today)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/expiring?within=soon", nil)
+		w := httptest.NewRecorder()
+		controller.GetExpiringProducts()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestCreateProduct(t *testing.T) {
 	t.Run("sucess should create a product", func(t *testing.T) {
 		// Arrange
 		mockSt := map[int]domain.Product{}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		product := `{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99}`
@@ -126,8 +541,8 @@ func TestCreateProduct(t *testing.T) {
 
 		// Assert
 		expectedCode := http.StatusCreated
-		expectedBody := `{"body":{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99, "id": 1}, "code": 201, "error": ""}`
-		expectedHeader := http.Header{"Content-Type": []string{"application/json"}}
+		expectedBody := `{"body":{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99, "id": 1, "version": 1}, "code": 201, "error": ""}`
+		expectedHeader := http.Header{"Content-Type": []string{"application/json"}, "Etag": []string{`"1"`}}
 
 		require.Equal(t, expectedCode, w.Code)
 		require.JSONEq(t, expectedBody, w.Body.String())
@@ -146,12 +561,13 @@ func TestDeleteProduct(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
@@ -187,12 +603,13 @@ func TestBadGetProductById(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
@@ -216,37 +633,22 @@ func TestBadGetProductById(t *testing.T) {
 	})
 }
 
-func TestGetUnexistentProductById(t *testing.T) {
-	t.Run("should return an error when the product does not exist", func(t *testing.T) {
+func TestBadSearchProduct(t *testing.T) {
+	t.Run("should return an error naming the invalid parameter", func(t *testing.T) {
 		// Arrange
-		mockSt := map[int]domain.Product{
-			1: {
-				Id:          1,
-				Name:        "Product 1",
-				Quantity:    10,
-				CodeValue:   "12345",
-				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
-			},
-		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
-		r := httptest.NewRequest("GET", "/products/2", nil)
+		r := httptest.NewRequest("GET", "/products/search?priceBetween=300,100", nil)
 		w := httptest.NewRecorder()
-
-		chiCtx := chi.NewRouteContext()
-		chiCtx.URLParams.Add("id", "2")
-		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
-
-		controller.GetProductById()(w, r)
+		controller.SearchProduct()(w, r)
 
 		// Assert
-		expectedCode := http.StatusNotFound
-		expectedBody := `{"body":null, "code": 404, "error": "product not found"}`
+		expectedCode := http.StatusBadRequest
+		expectedBody := `{"body":null, "code": 400, "error": "invalid query: priceBetween: lower bound is greater than upper bound"}`
 		expectedHeader := http.Header{"Content-Type": []string{"application/json"}}
 
 		require.Equal(t, expectedCode, w.Code)
@@ -255,33 +657,143 @@ func TestGetUnexistentProductById(t *testing.T) {
 	})
 }
 
-func TestPatchUnexistentProduct(t *testing.T) {
-	t.Run("should return an error when the product does not exist", func(t *testing.T) {
+func TestBadCreateProduct(t *testing.T) {
+	t.Run("should report malformed fields with the invalid ones with 422", func(t *testing.T) {
 		// Arrange
-		mockSt := map[int]domain.Product{
-			1: {
-				Id:          1,
-				Name:        "Product 1",
-				Quantity:    10,
-				CodeValue:   "12345",
-				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
-			},
-		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
-		productPatch := `{"name": "test patch"}`
+		product := `{"name": "", "quantity": "x", "code_value": "testcode", "is_published": true, "expiration": "12/31/2021", "price": true}`
 
 		// Act
-		r := httptest.NewRequest("GET", "/products/2", strings.NewReader(productPatch))
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(product))
 		w := httptest.NewRecorder()
+		controller.CreateProduct()(w, r)
 
-		chiCtx := chi.NewRouteContext()
-		chiCtx.URLParams.Add("id", "2")
-		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
+		// Assert
+		expectedCode := http.StatusUnprocessableEntity
+		expectedBody := `{"body":[
+			{"field":"quantity","code":"invalid","message":"quantity has an invalid value"},
+			{"field":"expiration","code":"invalid","message":"expiration: invalid date: \"12/31/2021\", expected DD/MM/YYYY or YYYY-MM-DD"},
+			{"field":"price","code":"invalid","message":"price: invalid money amount: \"true\" is not a number"},
+			{"field":"name","code":"required","message":"name is required"}
+		], "code": 422, "error": "validation failed"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should return an error when the body is not a JSON object", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(`{"name": `))
+		w := httptest.NewRecorder()
+		controller.CreateProduct()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestInvalidCreateProduct(t *testing.T) {
+	t.Run("should return every field violation with 422", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		product := `{"name": "", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021"}`
+
+		// Act
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		w := httptest.NewRecorder()
+		controller.CreateProduct()(w, r)
+
+		// Assert
+		expectedCode := http.StatusUnprocessableEntity
+		expectedBody := `{"body":[{"field":"name","code":"required","message":"name is required"},{"field":"price","code":"required","message":"price is required"}], "code": 422, "error": "validation failed"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+}
+
+func TestGetUnexistentProductById(t *testing.T) {
+	t.Run("should return an error when the product does not exist", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {
+				Id:          1,
+				Name:        "Product 1",
+				Quantity:    10,
+				CodeValue:   "12345",
+				IsPublished: true,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
+			},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/2", nil)
+		w := httptest.NewRecorder()
+
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "2")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
+
+		controller.GetProductById()(w, r)
+
+		// Assert
+		expectedCode := http.StatusNotFound
+		expectedBody := `{"body":null, "code": 404, "error": "product not found"}`
+		expectedHeader := http.Header{"Content-Type": []string{"application/json"}}
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+		require.Equal(t, expectedHeader, w.Header())
+	})
+}
+
+func TestPatchUnexistentProduct(t *testing.T) {
+	t.Run("should return an error when the product does not exist", func(t *testing.T) {
+		// Arrange
+		mockSt := map[int]domain.Product{
+			1: {
+				Id:          1,
+				Name:        "Product 1",
+				Quantity:    10,
+				CodeValue:   "12345",
+				IsPublished: true,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
+			},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		productPatch := `{"name": "test patch"}`
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/2", strings.NewReader(productPatch))
+		w := httptest.NewRecorder()
+
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "2")
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
 
 		controller.UpdatePatchProduct()(w, r)
 
@@ -296,6 +808,111 @@ func TestPatchUnexistentProduct(t *testing.T) {
 	})
 }
 
+func TestPatchProductDocument(t *testing.T) {
+	newMockStorage := func() map[int]domain.Product {
+		return map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, "")},
+		}
+	}
+	newRequest := func(contentType string, body string) *http.Request {
+		r := httptest.NewRequest("PATCH", "/products/1", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	t.Run("sucess should apply a merge patch", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.UpdatePatchProduct()(w, newRequest("application/merge-patch+json", `{"name": "Product 1 bis", "is_published": false}`))
+
+		// Assert
+		expectedCode := http.StatusOK
+		expectedBody := `{"body":{"id":1,"name":"Product 1 bis","quantity":10,"code_value":"12345","is_published":false,"expiration":"01/01/2023","price":100,"version":1}, "code": 200, "error": ""}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+		require.Equal(t, `"1"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("sucess should apply a json patch guarded by a test", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		body := `[{"op": "test", "path": "/quantity", "value": 10}, {"op": "replace", "path": "/quantity", "value": 8}]`
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.UpdatePatchProduct()(w, newRequest("application/json-patch+json", body))
+		wAgain := httptest.NewRecorder()
+		controller.UpdatePatchProduct()(wAgain, newRequest("application/json-patch+json", body))
+
+		// Assert
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, http.StatusConflict, wAgain.Code)
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, 8, product.Quantity)
+	})
+
+	t.Run("should validate the patched product before storing it", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		body := `{"id": 2, "name": null, "price": -1, "color": "red"}`
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.UpdatePatchProduct()(w, newRequest("application/merge-patch+json", body))
+
+		// Assert
+		expectedCode := http.StatusUnprocessableEntity
+		expectedBody := `{"body":[
+			{"field":"color","code":"unknown","message":"color is not a product field"},
+			{"field":"id","code":"immutable","message":"id cannot be changed"},
+			{"field":"name","code":"required","message":"name is required"},
+			{"field":"price","code":"min","message":"price must be positive"}
+		], "code": 422, "error": "validation failed"}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+
+		product, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, "Product 1", product.Name)
+	})
+
+	t.Run("should reject unsupported content types", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		w := httptest.NewRecorder()
+		controller.UpdatePatchProduct()(w, newRequest("text/plain", "name=x"))
+
+		// Assert
+		require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
+
 func TestDeleteUnexistentProduct(t *testing.T) {
 	t.Run("should return an error when the product does not exist", func(t *testing.T) {
 		// Arrange
@@ -306,12 +923,13 @@ func TestDeleteUnexistentProduct(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		// Act
@@ -345,16 +963,17 @@ func TestUnAuthDeleteProduct(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		router := chi.NewRouter()
-		router.Use(middleware.AuthValidationMid)
+		router.Use(middleware.AuthValidationMid(newKeyService(t, "secret")))
 		router.Delete("/products/{id}", controller.DeleteProduct())
 
 		// Act
@@ -384,18 +1003,19 @@ func TestUnAuthPostProduct(t *testing.T) {
 				Quantity:    10,
 				CodeValue:   "12345",
 				IsPublished: true,
-				Expiration:  "2023-01-01",
-				Price:       100.0,
+				Expiration:  domain.MustParseDate("2023-01-01"),
+				Price:       domain.MoneyFromFloat(100.0, ""),
 			},
 		}
-		mockRepository := repository.NewRepositoryProduct(mockSt, nil)
-		service := service.NewServiceProduct(mockRepository)
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
 		controller := controller.NewProductController(service)
 
 		product := `{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99}`
 
 		router := chi.NewRouter()
-		router.Use(middleware.AuthValidationMid)
+		router.Use(middleware.AuthValidationMid(newKeyService(t, "secret")))
 		router.Delete("/products/{id}", controller.DeleteProduct())
 
 		// Act
@@ -414,3 +1034,644 @@ func TestUnAuthPostProduct(t *testing.T) {
 		require.Equal(t, expectedHeader, w.Header())
 	})
 }
+
+func TestProblemDetails(t *testing.T) {
+	t.Run("sucess should describe errors as problem details when the client accepts them", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		router := chi.NewRouter()
+		router.Use(middleware.RequestIdMid)
+		router.Get("/products/search", controller.SearchProduct())
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/search?priceGt=cheap", nil)
+		r.Header.Set("Accept", "application/json, application/problem+json")
+		r.Header.Set("X-Request-Id", "req-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		// Assert
+		expectedCode := http.StatusBadRequest
+		expectedBody := fmt.Sprintf(`{"type":"about:blank","title":"Bad Request","status":400,"detail":%q,"instance":"/products/search?priceGt=cheap","request_id":"req-1"}`, problemDetail(t, w))
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+		require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		require.Equal(t, "req-1", w.Header().Get("X-Request-Id"))
+		require.True(t, strings.HasPrefix(problemDetail(t, w), "invalid query"))
+	})
+
+	t.Run("sucess should list the violations of an invalid request", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		product := `{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021"}`
+
+		// Act
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		r.Header.Set("Accept", "application/problem+json")
+		w := httptest.NewRecorder()
+		controller.CreateProduct()(w, r)
+
+		// Assert
+		expectedCode := http.StatusUnprocessableEntity
+		expectedBody := `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"validation failed","instance":"/products","errors":[{"field":"price","code":"required","message":"price is required"}]}`
+
+		require.Equal(t, expectedCode, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should use the status of an AppError", func(t *testing.T) {
+		// Arrange
+		r := httptest.NewRequest("GET", "/products", nil)
+		w := httptest.NewRecorder()
+		err := fmt.Errorf("checking stock: %w", utility.NewAppError(http.StatusTeapot, errors.New("no coffee")))
+
+		// Act
+		controller.HandleError(w, r, err)
+
+		// Assert
+		expectedBody := `{"body":null, "code": 418, "error": "checking stock: no coffee"}`
+
+		require.Equal(t, http.StatusTeapot, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("sucess should always give an error wrapping several known errors the same status", func(t *testing.T) {
+		// Arrange
+		err := fmt.Errorf("%w: %w", utility.ErrInvalidRequestBody, utility.ErrRequestTooLarge)
+
+		for i := 0; i < 20; i++ {
+			r := httptest.NewRequest("POST", "/products/import", nil)
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.HandleError(w, r, err)
+
+			// Assert
+			require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		}
+	})
+
+	t.Run("should not expose the text of unexpected errors", func(t *testing.T) {
+		// Arrange
+		err := fmt.Errorf("encoding order: %w", errors.New("json: unsupported value: NaN"))
+
+		for _, accept := range []string{"application/json", "application/problem+json"} {
+			r := httptest.NewRequest("GET", "/products", nil)
+			r.Header.Set("Accept", accept)
+			w := httptest.NewRecorder()
+
+			// Act
+			controller.HandleError(w, r, err)
+
+			// Assert
+			require.Equal(t, http.StatusInternalServerError, w.Code)
+			require.Contains(t, w.Body.String(), "Internal Server Error")
+			require.NotContains(t, w.Body.String(), "NaN")
+		}
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	newMockStorage := func() map[int]domain.Product {
+		return map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, ""), Version: 3},
+		}
+	}
+	product := `{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99}`
+
+	t.Run("sucess should answer not modified when the etag matches", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		r := withId(httptest.NewRequest("GET", "/products/1", nil), "1")
+		r.Header.Set("If-None-Match", `"2", W/"3"`)
+		w := httptest.NewRecorder()
+
+		// Act
+		controller.GetProductById()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusNotModified, w.Code)
+		require.Equal(t, `"3"`, w.Header().Get("ETag"))
+		require.Empty(t, w.Body.String())
+	})
+
+	t.Run("sucess should update the product when if-match holds its etag", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		r := withId(httptest.NewRequest("PUT", "/products/1", strings.NewReader(product)), "1")
+		r.Header.Set("If-Match", `"3"`)
+		w := httptest.NewRecorder()
+
+		// Act
+		controller.UpdateProduct()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"4"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("should reject writes with a stale etag", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		put := withId(httptest.NewRequest("PUT", "/products/1", strings.NewReader(product)), "1")
+		put.Header.Set("If-Match", `"2"`)
+		patch := withId(httptest.NewRequest("PATCH", "/products/1", strings.NewReader(`{"name": "Product 1 bis"}`)), "1")
+		patch.Header.Set("Content-Type", "application/merge-patch+json")
+		patch.Header.Set("If-Match", `W/"3"`)
+		del := withId(httptest.NewRequest("DELETE", "/products/1", nil), "1")
+		del.Header.Set("If-Match", `"2"`)
+
+		// Act
+		wPut, wPatch, wDel := httptest.NewRecorder(), httptest.NewRecorder(), httptest.NewRecorder()
+		controller.UpdateProduct()(wPut, put)
+		controller.UpdatePatchProduct()(wPatch, patch)
+		controller.DeleteProduct()(wDel, del)
+
+		// Assert
+		expectedBody := `{"body":null, "code": 412, "error": "precondition failed: the product was modified"}`
+
+		for _, w := range []*httptest.ResponseRecorder{wPut, wPatch, wDel} {
+			require.Equal(t, http.StatusPreconditionFailed, w.Code)
+			require.JSONEq(t, expectedBody, w.Body.String())
+		}
+
+		stored, err := mockRepository.GetProductById(1)
+		require.NoError(t, err)
+		require.Equal(t, "Product 1", stored.Name)
+		require.Equal(t, 3, stored.Version)
+	})
+}
+
+func TestBulkProducts(t *testing.T) {
+	newMockStorage := func() map[int]domain.Product {
+		return map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, ""), Version: 1},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: true, Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200.0, ""), Version: 1},
+		}
+	}
+	products := `[
+		{"name": "Product 3", "quantity": 1, "code_value": "333", "is_published": true, "expiration": "15/12/2021", "price": 3},
+		{"name": "Product 4", "quantity": 1, "code_value": "12345", "is_published": true, "expiration": "15/12/2021", "price": 4},
+		{"name": "Product 5", "quantity": 1, "code_value": "555", "is_published": true, "expiration": "15/12/2021", "price": 5}
+	]`
+
+	t.Run("sucess should create every product of the batch", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		body := `[
+			{"name": "Product 3", "quantity": 1, "code_value": "333", "is_published": true, "expiration": "15/12/2021", "price": 3},
+			{"name": "Product 4", "quantity": 1, "code_value": "444", "is_published": true, "expiration": "15/12/2021", "price": 4}
+		]`
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/bulk", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		controller.CreateProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"body":{"mode":"atomic","succeeded":2,"failed":0,"results":[
+			{"code":201,"error":"","body":{"id":3,"name":"Product 3","quantity":1,"code_value":"333","is_published":true,"expiration":"15/12/2021","price":3,"version":1}},
+			{"code":201,"error":"","body":{"id":4,"name":"Product 4","quantity":1,"code_value":"444","is_published":true,"expiration":"15/12/2021","price":4,"version":1}}
+		]}, "code": 201, "error": ""}`
+
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should roll back an atomic batch when an item fails", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/bulk?mode=atomic", strings.NewReader(products))
+		w := httptest.NewRecorder()
+		controller.CreateProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"body":{"mode":"atomic","succeeded":0,"failed":3,"results":[
+			{"code":424,"error":"not applied: another item of the batch failed","body":null},
+			{"code":400,"error":"code value already exists","body":null},
+			{"code":424,"error":"not applied: another item of the batch failed","body":null}
+		]}, "code": 400, "error": "item 1: code value already exists"}`
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+
+		stored, err := mockRepository.GetProducts()
+		require.NoError(t, err)
+		require.Len(t, stored, 2)
+	})
+
+	t.Run("sucess should apply the valid items of a best effort batch", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/bulk?mode=best_effort", strings.NewReader(products))
+		w := httptest.NewRecorder()
+		controller.CreateProducts()(w, r)
+
+		// Assert
+		var response struct {
+			Body utility.BulkReport `json:"body"`
+		}
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, 2, response.Body.Succeeded)
+		require.Equal(t, []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated},
+			[]int{response.Body.Results[0].Code, response.Body.Results[1].Code, response.Body.Results[2].Code})
+
+		stored, err := mockRepository.GetProducts()
+		require.NoError(t, err)
+		require.Len(t, stored, 4)
+	})
+
+	t.Run("sucess should patch and delete products in a best effort batch", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		body := `[
+			{"id": 1, "version": 1, "quantity": 5},
+			{"id": 2, "delete": true},
+			{"id": 1, "version": 1, "name": "stale"},
+			{"id": 9, "delete": true, "name": "x"}
+		]`
+
+		// Act
+		r := httptest.NewRequest("PATCH", "/products/bulk?mode=best_effort", strings.NewReader(body))
+		r = r.WithContext(auth.WithScopes(r.Context(), []auth.Scope{auth.ScopeCatalogWrite, auth.ScopeCatalogDelete}))
+		w := httptest.NewRecorder()
+		controller.PatchProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"body":{"mode":"best_effort","succeeded":2,"failed":2,"results":[
+			{"code":200,"error":"","body":{"id":1,"name":"Product 1","quantity":5,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":2}},
+			{"code":204,"error":"","body":null},
+			{"code":412,"error":"precondition failed: the product was modified","body":null},
+			{"code":422,"error":"validation failed","body":[{"field":"delete","code":"invalid","message":"delete cannot be combined with fields to change"}]}
+		]}, "code": 207, "error": ""}`
+
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+
+		_, err = mockRepository.GetProductById(2)
+		require.ErrorIs(t, err, utility.ErrProductNotFound)
+	})
+
+	t.Run("should reject an unknown mode", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/bulk?mode=some", strings.NewReader(products))
+		w := httptest.NewRecorder()
+		controller.CreateProducts()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestImportExportProducts(t *testing.T) {
+	newMockStorage := func() map[int]domain.Product {
+		return map[int]domain.Product{
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: false, Expiration: domain.MustParseDate("02/01/2023"), Price: domain.MoneyFromFloat(200.0, ""), Version: 1},
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, ""), Version: 1},
+		}
+	}
+
+	t.Run("sucess should export the catalog as ndjson ordered by id", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/export?format=ndjson", nil)
+		w := httptest.NewRecorder()
+		controller.ExportProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"01/01/2023","price":100,"version":1}` + "\n" +
+			`{"id":2,"name":"Product 2","quantity":20,"code_value":"67890","is_published":false,"expiration":"02/01/2023","price":200,"version":1}` + "\n"
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		require.Equal(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should reject an unknown export format", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("GET", "/products/export?format=xml", nil)
+		w := httptest.NewRecorder()
+		controller.ExportProducts()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("sucess should import the valid rows of a csv upload and report the others", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		upload := "name,quantity,code_value,is_published,expiration,price\n" +
+			"Product 3,3,333,true,15/12/2021,3\n" +
+			",4,444,true,15/12/2021,4\n" +
+			"Product 5,5,12345,true,15/12/2021,5\n"
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/import?mode=best_effort", strings.NewReader(upload))
+		r.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		w := httptest.NewRecorder()
+		controller.ImportProducts()(w, r)
+
+		// Assert
+		expectedBody := `{"body":{"format":"csv","mode":"best_effort","succeeded":1,"failed":2,"results":[
+			{"line":2,"code":201,"error":"","body":{"id":3,"name":"Product 3","quantity":3,"code_value":"333","is_published":true,"expiration":"15/12/2021","price":3,"version":1}},
+			{"line":3,"code":422,"error":"validation failed","body":[{"field":"name","code":"required","message":"name is required"}]},
+			{"line":4,"code":400,"error":"code value already exists","body":null}
+		]}, "code": 207, "error": ""}`
+
+		require.Equal(t, http.StatusMultiStatus, w.Code)
+		require.JSONEq(t, expectedBody, w.Body.String())
+	})
+
+	t.Run("should reject uploads larger than the size limit", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		upload := "name\n" + strings.Repeat("a", 17<<20) + "\n"
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/import", strings.NewReader(upload))
+		r.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+		controller.ImportProducts()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("should reject uploads that are not csv or ndjson", func(t *testing.T) {
+		// Arrange
+		mockRepository, err := repository.NewRepositoryProduct(newMockStorage(), nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		// Act
+		r := httptest.NewRequest("POST", "/products/import", strings.NewReader(`[]`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		controller.ImportProducts()(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+}
+
+func problemDetail(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var problem utility.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	return problem.Detail
+}
+
+func TestAccessControl(t *testing.T) {
+	keys := newKeyService(t, "secret")
+
+	policy := &auth.Policy{
+		Roles: map[string][]auth.Scope{
+			"reader": {auth.ScopeCatalogRead},
+			"editor": {auth.ScopeCatalogRead, auth.ScopeCatalogWrite},
+		},
+		Anonymous: []string{"reader"},
+		APIKeys:   map[string][]string{"default": {"editor"}},
+	}
+
+	newRouter := func() http.Handler {
+		mockSt := map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, ""), Version: 1},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		router := chi.NewRouter()
+		router.Use(middleware.OptionalAuthMid(middleware.AuthValidationMid(keys)))
+		router.With(middleware.RequireScopeMid(policy, auth.ScopeCatalogRead)).Get("/products/{id}", controller.GetProductById())
+		router.With(middleware.RequireScopeMid(policy, auth.ScopeCatalogWrite)).Patch("/products/bulk", controller.PatchProducts())
+		router.With(middleware.RequireScopeMid(policy, auth.ScopeCatalogDelete)).Delete("/products/{id}", controller.DeleteProduct())
+		return router
+	}
+
+	cases := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{"sucess should let anonymous clients read", "GET", "/products/1", "", "", http.StatusOK, ""},
+		{"should return 401 to anonymous clients without the scope", "DELETE", "/products/1", "", "", http.StatusUnauthorized,
+			`{"body":null, "code": 401, "error": "Unauthorized - Invalid Token"}`},
+		{"should return 401 for an invalid token", "GET", "/products/1", "", "wrong", http.StatusUnauthorized,
+			`{"body":null, "code": 401, "error": "Unauthorized - Invalid Token"}`},
+		{"should return 403 to authenticated clients without the scope", "DELETE", "/products/1", "", "secret", http.StatusForbidden,
+			`{"body":null, "code": 403, "error": "Forbidden - Insufficient Scope: catalog:delete is required"}`},
+		{"should return 403 for bulk deletes without the delete scope", "PATCH", "/products/bulk", `[{"id": 1, "delete": true}]`, "secret", http.StatusForbidden,
+			`{"body":null, "code": 403, "error": "Forbidden - Insufficient Scope: catalog:delete is required"}`},
+		{"sucess should let authenticated clients use their scopes", "PATCH", "/products/bulk", `[{"id": 1, "quantity": 5}]`, "secret", http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			r := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			if c.token != "" {
+				r.Header.Set("token", c.token)
+			}
+			w := httptest.NewRecorder()
+
+			// Act
+			newRouter().ServeHTTP(w, r)
+
+			// Assert
+			require.Equal(t, c.expectedCode, w.Code)
+			if c.expectedBody != "" {
+				require.JSONEq(t, c.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	signingSecret := []byte("partner-secret")
+	product := `{"name": "test", "quantity": 23, "code_value": "testcode", "is_published": true, "expiration": "15/12/2021", "price": 99}`
+
+	newRouter := func() http.Handler {
+		mockRepository, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		router := chi.NewRouter()
+		router.Use(middleware.AuthValidationMid(newKeyService(t, "secret")))
+		router.Use(middleware.SignatureMid(auth.NewSignatureVerifier(map[string][]byte{"default": signingSecret}, time.Minute)))
+		router.Post("/products", controller.CreateProduct())
+		return router
+	}
+
+	t.Run("sucess should pass the body of a signed request to the handler", func(t *testing.T) {
+		// Arrange
+		signed := auth.SignedRequest{Method: "POST", Path: "/products", Timestamp: fmt.Sprint(time.Now().Unix()), Nonce: "n-1", Body: []byte(product)}
+
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		r.Header.Set("token", "secret")
+		r.Header.Set(auth.HeaderTimestamp, signed.Timestamp)
+		r.Header.Set(auth.HeaderNonce, signed.Nonce)
+		r.Header.Set(auth.HeaderSignature, auth.Sign(signingSecret, signed))
+		w := httptest.NewRecorder()
+
+		// Act
+		newRouter().ServeHTTP(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("should reject an unsigned request of a client with a signing secret", func(t *testing.T) {
+		// Arrange
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(product))
+		r.Header.Set("token", "secret")
+		w := httptest.NewRecorder()
+
+		// Act
+		newRouter().ServeHTTP(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("should reject a signed request whose body is too large to verify", func(t *testing.T) {
+		// Arrange
+		r := httptest.NewRequest("POST", "/products", strings.NewReader(strings.Repeat(" ", 17<<20)))
+		r.Header.Set("token", "secret")
+		w := httptest.NewRecorder()
+
+		// Act
+		newRouter().ServeHTTP(w, r)
+
+		// Assert
+		require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+func TestClientCertAuth(t *testing.T) {
+	newRouter := func(t *testing.T) http.Handler {
+		mockSt := map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("01/01/2023"), Price: domain.MoneyFromFloat(100.0, ""), Version: 1},
+		}
+		mockRepository, err := repository.NewRepositoryProduct(mockSt, nil)
+		require.NoError(t, err)
+		service := service.NewServiceProduct(mockRepository, nil)
+		controller := controller.NewProductController(service)
+
+		policy := &auth.Policy{
+			Roles:    map[string][]auth.Scope{"deleter": {auth.ScopeCatalogDelete}},
+			Subjects: map[string][]string{"partner-2": {"deleter"}},
+			Certs:    map[string][]string{"acme": {"deleter"}},
+		}
+		identities := auth.CertIdentities{"CN=partner-1,O=Acme": "acme", "CN=partner-2,O=Acme": "partner-2"}
+
+		router := chi.NewRouter()
+		router.Use(middleware.OptionalAuthMid(middleware.ClientCertAuthMid(identities, middleware.AuthValidationMid(newKeyService(t, "secret")))))
+		router.With(middleware.RequireScopeMid(policy, auth.ScopeCatalogDelete)).Delete("/products/{id}", controller.DeleteProduct())
+		return router
+	}
+
+	deleteWithCert := func(router http.Handler, subject pkix.Name) int {
+		cert := &x509.Certificate{Subject: subject}
+		r := httptest.NewRequest("DELETE", "/products/1", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("sucess should authorize a verified client certificate as its identity", func(t *testing.T) {
+		// Act
+		code := deleteWithCert(newRouter(t), pkix.Name{CommonName: "partner-1", Organization: []string{"Acme"}})
+
+		// Assert
+		require.Equal(t, http.StatusNoContent, code)
+	})
+
+	t.Run("should reject client certificates without an identity", func(t *testing.T) {
+		// Act
+		code := deleteWithCert(newRouter(t), pkix.Name{CommonName: "acme"})
+
+		// Assert
+		require.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("should not grant the roles of token subjects to certificate identities", func(t *testing.T) {
+		// Act
+		code := deleteWithCert(newRouter(t), pkix.Name{CommonName: "partner-2", Organization: []string{"Acme"}})
+
+		// Assert
+		require.Equal(t, http.StatusForbidden, code)
+	})
+}
@@ -1,21 +1,204 @@
 package middleware
 
 import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"os"
+	"strings"
 
+	"github.com/MDavidCV/go-web-module/internal/auth"
 	"github.com/MDavidCV/go-web-module/internal/handler/controller"
+	"github.com/MDavidCV/go-web-module/internal/service"
 	"github.com/MDavidCV/go-web-module/utility"
 )
 
-func AuthValidationMid(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		api_key := os.Getenv("API_KEY")
-		if api_key != r.Header.Get("token") || api_key == "" {
-			controller.HandleResponse(w, utility.NewUnauthorizedResponse())
-			return
-		}
+// AuthValidationMid requires an API key of keys in the token header. The key is the client of the access policy:
+// its id is the subject of the request and its scopes are granted to it.
+func AuthValidationMid(keys service.ServiceAPIKey) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, err := keys.Authenticate(r.Header.Get("token"))
+			if err != nil {
+				controller.HandleError(w, r, err)
+				return
+			}
 
-		handler.ServeHTTP(w, r)
-	})
+			claims := auth.Claims{Subject: key.Id, APIKey: key.Id}
+			for _, scope := range key.Scopes {
+				claims.Scopes = append(claims.Scopes, auth.Scope(scope))
+			}
+
+			setLoggedSubject(r, key.Id)
+			handler.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// JWTAuthMid requires a bearer token accepted by verifier in the Authorization header. The claims of the token
+// are stored in the request context, see auth.ClaimsFromContext, and its subject is logged with the request.
+func JWTAuthMid(verifier auth.Verifier) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				controller.HandleError(w, r, utility.ErrUnauthorized)
+				return
+			}
+
+			claims, err := verifier.Verify(strings.TrimSpace(token))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				controller.HandleError(w, r, err)
+				return
+			}
+
+			setLoggedSubject(r, claims.Subject)
+			handler.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// CredentialsAuthMid authenticates the requests with an Authorization header with bearerMid, and the others with
+// apiKeyMid.
+func CredentialsAuthMid(bearerMid, apiKeyMid func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		bearer, apiKey := bearerMid(handler), apiKeyMid(handler)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				bearer.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientCert returns the client certificate of r verified by the TLS handshake, or nil.
+func clientCert(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return r.TLS.VerifiedChains[0][0]
+}
+
+// ClientCertAuthMid authenticates the requests with a verified client certificate and no other credentials as the
+// identity of the certificate in identities, and the others with authMid. Certificates without an identity are
+// rejected.
+func ClientCertAuthMid(identities auth.CertIdentities, authMid func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		authenticated := authMid(handler)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cert := clientCert(r)
+			if cert == nil || r.Header.Get("Authorization") != "" || r.Header.Get("token") != "" {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			identity := identities.Identity(cert)
+			if identity == "" {
+				controller.HandleError(w, r, fmt.Errorf("%w: the client certificate has no identity", utility.ErrUnauthorized))
+				return
+			}
+
+			setLoggedSubject(r, identity)
+			handler.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), auth.Claims{Subject: identity, Certificate: identity})))
+		})
+	}
+}
+
+// OptionalAuthMid authenticates the requests with credentials with authMid, and lets the requests without
+// credentials through anonymously.
+func OptionalAuthMid(authMid func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		authenticated := authMid(handler)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" && r.Header.Get("token") == "" && clientCert(r) == nil {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScopeMid grants the client the scopes of its roles in policy and rejects the request unless they include
+// every scope of scopes: with 401 Unauthorized for anonymous requests and 403 Forbidden for authenticated ones.
+// The granted scopes are stored in the request context, see auth.HasScope.
+func RequireScopeMid(policy *auth.Policy, scopes ...auth.Scope) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var granted []auth.Scope
+			claims, authenticated := auth.ClaimsFromContext(r.Context())
+			if authenticated {
+				granted = policy.Scopes(&claims)
+			} else {
+				granted = policy.Scopes(nil)
+			}
+
+			ctx := auth.WithScopes(r.Context(), granted)
+			for _, scope := range scopes {
+				if auth.HasScope(ctx, scope) {
+					continue
+				}
+
+				if !authenticated {
+					controller.HandleError(w, r, utility.ErrUnauthorized)
+					return
+				}
+				controller.HandleError(w, r, fmt.Errorf("%w: %s is required", utility.ErrForbidden, scope))
+				return
+			}
+
+			handler.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// maxSignedBodyBytes bounds the body read to verify a signature, as large as the largest write, an import upload.
+const maxSignedBodyBytes = 16 << 20
+
+// SignatureMid requires the clients with a signing secret in verifier to sign their requests, see auth.Sign. It
+// runs after the authentication middleware, which identifies the client. Requests of other clients go through.
+func SignatureMid(verifier auth.SignatureVerifier) func(http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			subject := auth.SubjectFromContext(r.Context())
+			if subject == "" || !verifier.Requires(subject) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				controller.HandleError(w, r, fmt.Errorf("%w: at most %d bytes", utility.ErrRequestTooLarge, tooLarge.Limit))
+				return
+			}
+			if err != nil {
+				controller.HandleError(w, r, utility.ErrInvalidRequestBody)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			req := auth.SignedRequest{
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Timestamp: r.Header.Get(auth.HeaderTimestamp),
+				Nonce:     r.Header.Get(auth.HeaderNonce),
+				Body:      body,
+			}
+			if err := verifier.Verify(subject, req, r.Header.Get(auth.HeaderSignature)); err != nil {
+				controller.HandleError(w, r, err)
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// RequestIdHeader carries the id of a request, from the client or generated by RequestIdMid.
+const RequestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength bounds the ids accepted from clients, which are echoed back and logged.
+const maxRequestIdLength = 128
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestIdMid gives every request an id, reusing the one sent by the client if any. The id is stored
+// in the request context, see utility.RequestIdFromContext, and sent back in the X-Request-Id header.
+func RequestIdMid(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if id == "" || len(id) > maxRequestIdLength {
+			id = newRequestId()
+		}
+
+		w.Header().Set(RequestIdHeader, id)
+		handler.ServeHTTP(w, r.WithContext(utility.WithRequestId(r.Context(), id)))
+	})
+}
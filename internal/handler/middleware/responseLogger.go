@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
@@ -30,6 +31,22 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode       // capture status code
 }
 
+// loggedRequestKey holds the *loggedRequest of a request in its context.
+type loggedRequestKey struct{}
+
+// loggedRequest collects what the middlewares inside ResponseLoggerMid learn about a request.
+type loggedRequest struct {
+	// subject is the authenticated client, empty for anonymous requests.
+	subject string
+}
+
+// setLoggedSubject records the authenticated client of r, to be logged by ResponseLoggerMid.
+func setLoggedSubject(r *http.Request, subject string) {
+	if logged, ok := r.Context().Value(loggedRequestKey{}).(*loggedRequest); ok {
+		logged.subject = subject
+	}
+}
+
 func ResponseLoggerMid(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -42,19 +59,28 @@ func ResponseLoggerMid(handler http.Handler) http.Handler {
 			responseData:   responseData,
 		}
 
+		logged := &loggedRequest{}
+		r = r.WithContext(context.WithValue(r.Context(), loggedRequestKey{}, logged))
+
 		startTime := time.Now()
 		handler.ServeHTTP(&lrw, r)
 		endTime := time.Now()
 
 		duration := endTime.Sub(startTime)
 
-		log.Printf("Request: %s %s %s %s %d %d %s",
+		subject := logged.subject
+		if subject == "" {
+			subject = "-"
+		}
+
+		log.Printf("Request: %s %s %s %s %d %d %s %s",
 			startTime.Format("2006-01-02 15:04:05"),
 			r.Method,
 			r.URL.Path,
 			r.Proto,
 			responseData.status,
 			responseData.size,
-			duration)
+			duration,
+			subject)
 	})
 }
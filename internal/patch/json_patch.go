@@ -0,0 +1,298 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// operation is one operation of a JSON Patch. Value is nil when the member is missing.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+type jsonPatch struct {
+	operations []operation
+}
+
+// NewJSONPatch parses body as a JSON Patch, checking every operation is well formed.
+func NewJSONPatch(body []byte) (*jsonPatch, error) {
+	var operations []operation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return nil, fmt.Errorf("%w: %v", utility.ErrInvalidPatch, err)
+	}
+
+	for i, op := range operations {
+		if _, err := parsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", utility.ErrInvalidPatch, i, err)
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d: %s needs a value", utility.ErrInvalidPatch, i, op.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: from: %v", utility.ErrInvalidPatch, i, err)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d: unknown op %q", utility.ErrInvalidPatch, i, op.Op)
+		}
+	}
+
+	return &jsonPatch{operations: operations}, nil
+}
+
+// Apply applies the operations in order. The patch is atomic: when any operation fails, including a test,
+// an error wrapping utility.ErrPatchFailed is returned and no change is kept.
+func (jp *jsonPatch) Apply(doc []byte) ([]byte, error) {
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range jp.operations {
+		if root, err = applyOperation(root, op); err != nil {
+			return nil, fmt.Errorf("%w: operation %d (%s %s): %v", utility.ErrPatchFailed, i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func applyOperation(root any, op operation) (any, error) {
+	path, _ := parsePointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "remove":
+		root, _, err := remove(root, path)
+		return root, err
+
+	case "replace":
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if root, _, err = remove(root, path); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "move":
+		from, _ := parsePointer(op.From)
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into one of its children", op.From)
+		}
+		root, value, err := remove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+		// Copy the value so later operations on either location do not change the other.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = decode(data); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	case "test":
+		expected, err := decode(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(expected, actual) {
+			return nil, fmt.Errorf("test failed: value is %s", mustMarshal(actual))
+		}
+		return root, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+func mustMarshal(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses token as an index of an array of length items. The end index is allowed when
+// insert is true, and "-" then means the end of the array.
+func arrayIndex(token string, length int, insert bool) (int, error) {
+	if insert && token == "-" {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	limit := length - 1
+	if insert {
+		limit = length
+	}
+	if index > limit {
+		return 0, fmt.Errorf("array index %d out of range", index)
+	}
+
+	return index, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []any:
+			index, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[index]
+		default:
+			return nil, fmt.Errorf("cannot reference %q inside a scalar", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// update replaces the container holding the last token of path with the result of fn,
+// and returns the resulting document.
+func update(doc any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch container := doc.(type) {
+	case map[string]any:
+		child, ok := container[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q does not exist", path[0])
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[path[0]] = child
+		return container, nil
+
+	case []any:
+		index, err := arrayIndex(path[0], len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(container[index], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		container[index] = child
+		return container, nil
+	}
+
+	return nil, fmt.Errorf("cannot reference %q inside a scalar", path[0])
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(container any, token string) (any, error) {
+		switch container := container.(type) {
+		case map[string]any:
+			container[token] = value
+			return container, nil
+		case []any:
+			index, err := arrayIndex(token, len(container), true)
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar", token)
+	})
+}
+
+// remove removes the value at path and returns the resulting document and the removed value.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed any
+	doc, err := update(doc, path, func(container any, token string) (any, error) {
+		switch container := container.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			removed = value
+			delete(container, token)
+			return container, nil
+		case []any:
+			index, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = container[index]
+			return append(container[:index], container[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+	})
+
+	return doc, removed, err
+}
@@ -0,0 +1,55 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type mergePatch struct {
+	patch any
+}
+
+// NewMergePatch parses body as a JSON Merge Patch.
+func NewMergePatch(body []byte) (*mergePatch, error) {
+	value, err := decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utility.ErrInvalidPatch, err)
+	}
+
+	return &mergePatch{patch: value}, nil
+}
+
+func (mp *mergePatch) Apply(doc []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, mp.patch))
+}
+
+// merge implements the MergePatch function of RFC 7396: members of an object patch are merged
+// recursively into target, null members are removed, and any other patch replaces target.
+func merge(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = merge(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
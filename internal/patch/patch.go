@@ -0,0 +1,90 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents to JSON documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Media types of the supported patch formats.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// Patch changes a JSON document. Malformed patches are reported with utility.ErrInvalidPatch,
+// patches that cannot be applied with utility.ErrPatchFailed.
+type Patch interface {
+	// Apply returns doc with the patch applied, leaving doc unchanged.
+	Apply(doc []byte) ([]byte, error)
+}
+
+// New parses body as a patch of the format of contentType, one of the media type constants.
+func New(contentType string, body []byte) (Patch, error) {
+	switch contentType {
+	case MergePatchContentType:
+		return NewMergePatch(body)
+	case JSONPatchContentType:
+		return NewJSONPatch(body)
+	}
+	return nil, fmt.Errorf("%w: %q is not a patch format", utility.ErrUnsupportedMediaType, contentType)
+}
+
+// decode decodes a single JSON value of data. Numbers are kept as json.Number, so they are written back exactly.
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	return value, nil
+}
+
+// equal reports whether the decoded JSON values a and b are equal, numbers being compared by value.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Rat).SetString(a.String())
+		y, okB := new(big.Rat).SetString(b.String())
+		return okA && okB && x.Cmp(y) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
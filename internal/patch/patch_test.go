@@ -0,0 +1,126 @@
+package patch_test
+
+import (
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/patch"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	t.Run("sucess should merge objects and remove null members", func(t *testing.T) {
+		// Arrange
+		doc := `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`
+		body := `{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`
+
+		p, err := patch.NewMergePatch([]byte(body))
+		require.NoError(t, err)
+
+		// Act
+		patched, err := p.Apply([]byte(doc))
+
+		// Assert
+		require.NoError(t, err)
+		require.JSONEq(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`, string(patched))
+	})
+
+	t.Run("sucess should keep numbers exactly as written", func(t *testing.T) {
+		// Arrange
+		p, err := patch.NewMergePatch([]byte(`{"price":0.1000000000000000055511151231257827}`))
+		require.NoError(t, err)
+
+		// Act
+		patched, err := p.Apply([]byte(`{"id":9007199254740993,"price":1}`))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, `{"id":9007199254740993,"price":0.1000000000000000055511151231257827}`, string(patched))
+	})
+}
+
+func TestJSONPatch(t *testing.T) {
+	t.Run("sucess should apply every operation in order", func(t *testing.T) {
+		// Arrange
+		doc := `{"a":{"b":["x","y"]},"c":1,"d/e":true}`
+		body := `[
+			{"op":"test","path":"/c","value":1.0},
+			{"op":"add","path":"/a/b/1","value":"w"},
+			{"op":"add","path":"/a/b/-","value":"z"},
+			{"op":"remove","path":"/a/b/0"},
+			{"op":"replace","path":"/c","value":null},
+			{"op":"move","from":"/d~1e","path":"/f"},
+			{"op":"copy","from":"/a","path":"/g"}
+		]`
+
+		p, err := patch.NewJSONPatch([]byte(body))
+		require.NoError(t, err)
+
+		// Act
+		patched, err := p.Apply([]byte(doc))
+
+		// Assert
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a":{"b":["w","y","z"]},"c":null,"f":true,"g":{"b":["w","y","z"]}}`, string(patched))
+	})
+
+	t.Run("should not apply any operation when a test fails", func(t *testing.T) {
+		// Arrange
+		doc := []byte(`{"a":1}`)
+		p, err := patch.NewJSONPatch([]byte(`[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":3}]`))
+		require.NoError(t, err)
+
+		// Act
+		_, err = p.Apply(doc)
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrPatchFailed)
+		require.Equal(t, `{"a":1}`, string(doc))
+	})
+
+	t.Run("should compare numbers exactly when testing", func(t *testing.T) {
+		// Arrange
+		p, err := patch.NewJSONPatch([]byte(`[{"op":"test","path":"/id","value":9007199254740992}]`))
+		require.NoError(t, err)
+
+		// Act
+		_, err = p.Apply([]byte(`{"id":9007199254740993}`))
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrPatchFailed)
+	})
+
+	t.Run("should reject malformed operations", func(t *testing.T) {
+		for _, body := range []string{
+			`{"op":"add"}`,
+			`[{"op":"add","path":"/a"}]`,
+			`[{"op":"jump","path":"/a"}]`,
+			`[{"op":"remove","path":"a"}]`,
+		} {
+			// Act
+			_, err := patch.NewJSONPatch([]byte(body))
+
+			// Assert
+			require.ErrorIs(t, err, utility.ErrInvalidPatch, body)
+		}
+	})
+
+	t.Run("should fail to remove a missing member or index", func(t *testing.T) {
+		for _, body := range []string{
+			`[{"op":"remove","path":"/missing"}]`,
+			`[{"op":"replace","path":"/a/5","value":1}]`,
+			`[{"op":"add","path":"/a/01","value":1}]`,
+			`[{"op":"move","from":"/a","path":"/a/0"}]`,
+		} {
+			// Arrange
+			p, err := patch.NewJSONPatch([]byte(body))
+			require.NoError(t, err, body)
+
+			// Act
+			_, err = p.Apply([]byte(`{"a":[0]}`))
+
+			// Assert
+			require.ErrorIs(t, err, utility.ErrPatchFailed, body)
+		}
+	})
+}
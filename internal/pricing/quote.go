@@ -0,0 +1,155 @@
+package pricing
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Line is a product ordered Quantity times.
+type Line struct {
+	Product  domain.Product
+	Quantity int
+}
+
+// AppliedRule is a rule that changed an amount, and by how much.
+type AppliedRule struct {
+	Rule   string       `json:"rule"`
+	Amount domain.Money `json:"amount"`
+}
+
+// LineQuote is the priced form of a Line.
+type LineQuote struct {
+	ProductId int          `json:"product_id"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice domain.Money `json:"unit_price"`
+	// Subtotal is UnitPrice times Quantity, Total the subtotal minus the discount.
+	Subtotal domain.Money `json:"subtotal"`
+	Discount *AppliedRule `json:"discount,omitempty"`
+	Total    domain.Money `json:"total"`
+}
+
+// Quote is the itemized price of an order.
+type Quote struct {
+	Lines []LineQuote `json:"lines"`
+	Items int         `json:"items"`
+	// Subtotal is the sum of the line totals.
+	Subtotal  domain.Money `json:"subtotal"`
+	Surcharge AppliedRule  `json:"surcharge"`
+	Total     domain.Money `json:"total"`
+}
+
+// bestDiscount returns the largest discount applying to product, if any.
+func (r *Rules) bestDiscount(product domain.Product) (Discount, bool) {
+	var best Discount
+	var found bool
+
+	for _, discount := range r.Discounts {
+		matches := discount.ProductId == product.Id ||
+			discount.CodePrefix != "" && strings.HasPrefix(product.CodeValue, discount.CodePrefix)
+		if matches && discount.Percent > best.Percent {
+			best, found = discount, true
+		}
+	}
+
+	return best, found
+}
+
+// tier returns the tier applying to an order of items items.
+func (r *Rules) tier(items int) (int, Tier) {
+	for i, tier := range r.Tiers {
+		if tier.MaxItems == 0 || items <= tier.MaxItems {
+			return i, tier
+		}
+	}
+	last := len(r.Tiers) - 1
+	return last, r.Tiers[last]
+}
+
+// Evaluate prices lines, applying the best discount of each line and the surcharge tier of the order.
+// Every line must be priced in the same currency. It returns an error wrapping utility.ErrMinimumOrder
+// when the discounted subtotal is below Rules.MinimumOrder, and one wrapping domain.ErrMoneyOverflow when an
+// amount of the quote is too large.
+func (r *Rules) Evaluate(lines []Line) (Quote, error) {
+	quote, err := r.Price(lines)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	minimum, err := r.minimum(quote.Subtotal.Currency)
+	if err != nil {
+		return Quote{}, err
+	}
+	if quote.Subtotal.Amount < minimum.Amount {
+		return Quote{}, fmt.Errorf("%w: subtotal %s is below %s", utility.ErrMinimumOrder, quote.Subtotal, minimum)
+	}
+
+	return quote, nil
+}
+
+// Price is Evaluate without the minimum order check, for orders still being put together such as carts.
+func (r *Rules) Price(lines []Line) (Quote, error) {
+	currency := domain.DefaultCurrency
+	if len(lines) > 0 {
+		currency = lines[0].Product.Price.Currency
+	}
+
+	quote := Quote{
+		Lines:    make([]LineQuote, 0, len(lines)),
+		Subtotal: domain.Money{Currency: currency},
+	}
+
+	for _, line := range lines {
+		if line.Product.Price.Currency != currency {
+			return Quote{}, fmt.Errorf("%w: %s and %s", utility.ErrCurrencyMismatch, currency, line.Product.Price.Currency)
+		}
+
+		subtotal, err := line.Product.Price.Mul(line.Quantity)
+		if err != nil {
+			return Quote{}, fmt.Errorf("product %d: %w", line.Product.Id, err)
+		}
+
+		lineQuote := LineQuote{
+			ProductId: line.Product.Id,
+			Quantity:  line.Quantity,
+			UnitPrice: line.Product.Price,
+			Subtotal:  subtotal,
+			Total:     subtotal,
+		}
+
+		if discount, ok := r.bestDiscount(line.Product); ok {
+			amount, err := r.scale(lineQuote.Subtotal, new(big.Rat).Quo(ratFromFloat(discount.Percent), big.NewRat(100, 1)))
+			if err != nil {
+				return Quote{}, err
+			}
+			lineQuote.Discount = &AppliedRule{Rule: discount.Name, Amount: amount}
+			if lineQuote.Total, err = lineQuote.Total.Sub(amount); err != nil {
+				return Quote{}, err
+			}
+		}
+
+		quote.Lines = append(quote.Lines, lineQuote)
+		quote.Items += line.Quantity
+		if quote.Subtotal, err = quote.Subtotal.Add(lineQuote.Total); err != nil {
+			return Quote{}, err
+		}
+	}
+
+	i, tier := r.tier(quote.Items)
+	surcharge, err := r.scale(quote.Subtotal, ratFromFloat(tier.Rate))
+	if err != nil {
+		return Quote{}, err
+	}
+	quote.Surcharge = AppliedRule{
+		Rule:   fmt.Sprintf("tier %d (%g%%)", i+1, tier.Rate*100),
+		Amount: surcharge,
+	}
+	if quote.Total, err = quote.Subtotal.Add(quote.Surcharge.Amount); err != nil {
+		return Quote{}, err
+	}
+
+	return quote, nil
+}
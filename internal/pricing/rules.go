@@ -0,0 +1,194 @@
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// Tier is a surcharge applied to an order according to its number of items.
+type Tier struct {
+	// MaxItems is the highest item count the tier applies to, 0 meaning no upper bound.
+	MaxItems int `json:"max_items" yaml:"max_items"`
+	// Rate is the surcharge as a fraction of the subtotal, e.g. 0.21 for 21%.
+	Rate float64 `json:"rate" yaml:"rate"`
+}
+
+// Discount lowers the price of the lines matching ProductId or CodePrefix.
+type Discount struct {
+	Name       string  `json:"name" yaml:"name"`
+	ProductId  int     `json:"product_id,omitempty" yaml:"product_id,omitempty"`
+	CodePrefix string  `json:"code_prefix,omitempty" yaml:"code_prefix,omitempty"`
+	Percent    float64 `json:"percent" yaml:"percent"`
+}
+
+// Rounding describes how computed amounts, such as discounts and surcharges, are rounded.
+type Rounding struct {
+	// Mode is one of the domain rounding modes, domain.RoundHalfUp by default.
+	Mode string `json:"mode" yaml:"mode"`
+	// Decimals is the number of decimals kept, the minor unit of the currency when nil.
+	Decimals *int `json:"decimals,omitempty" yaml:"decimals,omitempty"`
+}
+
+// Rules is the configuration of the consumer price calculation.
+type Rules struct {
+	// Tiers are sorted by MaxItems, the last one having no upper bound.
+	Tiers     []Tier     `json:"tiers" yaml:"tiers"`
+	Discounts []Discount `json:"discounts" yaml:"discounts"`
+	// MinimumOrder is the lowest subtotal, after discounts, accepted for an order.
+	MinimumOrder float64  `json:"minimum_order" yaml:"minimum_order"`
+	Rounding     Rounding `json:"rounding" yaml:"rounding"`
+
+	// minimumOrder is MinimumOrder as an exact amount in major units, set by Validate.
+	minimumOrder *big.Rat
+}
+
+// maxCurrencyExponent is the largest number of minor unit digits of a currency, see domain.CurrencyExponent.
+const maxCurrencyExponent = 3
+
+// DefaultRules returns the historical pricing: a 21%, 17% or 15% surcharge for
+// up to 10, up to 20 and more than 20 items, without discounts, rounded half up to the minor unit.
+func DefaultRules() *Rules {
+	return &Rules{
+		Tiers: []Tier{
+			{MaxItems: 10, Rate: 0.21},
+			{MaxItems: 20, Rate: 0.17},
+			{MaxItems: 0, Rate: 0.15},
+		},
+		Rounding:     Rounding{Mode: domain.RoundHalfUp},
+		minimumOrder: new(big.Rat),
+	}
+}
+
+// LoadRules reads rules from a YAML file when filename ends in .yaml or .yml, and from a JSON file otherwise.
+func LoadRules(filename string) (*Rules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, rules)
+	default:
+		err = json.Unmarshal(data, rules)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode pricing rules: %w", err)
+	}
+
+	if rules.Rounding.Mode == "" {
+		rules.Rounding.Mode = domain.RoundHalfUp
+	}
+
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// Validate checks the rules are consistent. Rules must be validated before they are used to price orders.
+func (r *Rules) Validate() error {
+	if len(r.Tiers) == 0 {
+		return fmt.Errorf("pricing rules: at least one tier is required")
+	}
+
+	for i, tier := range r.Tiers {
+		last := i == len(r.Tiers)-1
+		switch {
+		case tier.Rate < 0:
+			return fmt.Errorf("pricing rules: tier %d: rate cannot be negative", i)
+		case last && tier.MaxItems != 0:
+			return fmt.Errorf("pricing rules: tier %d: the last tier cannot have max_items", i)
+		case !last && tier.MaxItems <= 0:
+			return fmt.Errorf("pricing rules: tier %d: max_items must be positive", i)
+		case i > 0 && !last && tier.MaxItems <= r.Tiers[i-1].MaxItems:
+			return fmt.Errorf("pricing rules: tier %d: tiers must be sorted by max_items", i)
+		}
+	}
+
+	for i, discount := range r.Discounts {
+		switch {
+		case discount.Name == "":
+			return fmt.Errorf("pricing rules: discount %d: name is required", i)
+		case discount.ProductId == 0 && discount.CodePrefix == "":
+			return fmt.Errorf("pricing rules: discount %q: product_id or code_prefix is required", discount.Name)
+		case discount.Percent <= 0 || discount.Percent > 100:
+			return fmt.Errorf("pricing rules: discount %q: percent must be between 0 and 100", discount.Name)
+		}
+	}
+
+	if math.IsNaN(r.MinimumOrder) || math.IsInf(r.MinimumOrder, 0) {
+		return fmt.Errorf("pricing rules: minimum_order must be a finite amount")
+	}
+	if r.MinimumOrder < 0 {
+		return fmt.Errorf("pricing rules: minimum_order cannot be negative")
+	}
+	minimumOrder := ratFromFloat(r.MinimumOrder)
+	maxMinimum := new(big.Rat).SetFrac(big.NewInt(math.MaxInt64), pow10(maxCurrencyExponent))
+	if minimumOrder.Cmp(maxMinimum) > 0 {
+		return fmt.Errorf("pricing rules: minimum_order overflows the amounts of some currencies")
+	}
+
+	switch r.Rounding.Mode {
+	case domain.RoundHalfUp, domain.RoundHalfEven, domain.RoundUp, domain.RoundDown:
+	default:
+		return fmt.Errorf("pricing rules: unknown rounding mode %q", r.Rounding.Mode)
+	}
+	if r.Rounding.Decimals != nil && *r.Rounding.Decimals < 0 {
+		return fmt.Errorf("pricing rules: rounding decimals cannot be negative")
+	}
+
+	r.minimumOrder = minimumOrder
+	return nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// minimum returns the minimum order in currency, rounded half up to its minor unit.
+func (r *Rules) minimum(currency string) (domain.Money, error) {
+	if r.minimumOrder == nil {
+		return domain.Money{}, fmt.Errorf("pricing rules: the rules were not validated")
+	}
+
+	minor := new(big.Rat).Mul(r.minimumOrder, new(big.Rat).SetInt(pow10(domain.CurrencyExponent(currency))))
+	return domain.Money{Amount: domain.RoundRat(minor, domain.RoundHalfUp), Currency: currency}, nil
+}
+
+// ratFromFloat converts f exactly as written, e.g. 0.21 is 21/100 and not its binary approximation.
+func ratFromFloat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// scale returns amount multiplied by factor, rounded with the rounding of the rules.
+func (r *Rules) scale(amount domain.Money, factor *big.Rat) (domain.Money, error) {
+	// quantum is the smallest amount kept, in minor units.
+	quantum := int64(1)
+	if r.Rounding.Decimals != nil {
+		for i := *r.Rounding.Decimals; i < domain.CurrencyExponent(amount.Currency); i++ {
+			quantum *= 10
+		}
+	}
+
+	scaled := new(big.Rat).Mul(amount.Rat(), factor)
+	scaled.Quo(scaled, new(big.Rat).SetInt64(quantum))
+
+	rounded, err := domain.MoneyFromRat(scaled, amount.Currency, r.Rounding.Mode)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	return rounded.Mul(int(quantum))
+}
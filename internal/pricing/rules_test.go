@@ -0,0 +1,139 @@
+package pricing_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRules(t *testing.T, name, content string) string {
+	filename := t.TempDir() + "/" + name
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	return filename
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Run("sucess default rules should keep the historical surcharges", func(t *testing.T) {
+		// Arrange
+		rules := pricing.DefaultRules()
+		product := domain.Product{Id: 1, CodeValue: "12345", Price: domain.MoneyFromFloat(100, "")}
+
+		// Act
+		small, err := rules.Evaluate([]pricing.Line{{Product: product, Quantity: 10}})
+		require.NoError(t, err)
+		medium, err := rules.Evaluate([]pricing.Line{{Product: product, Quantity: 11}})
+		require.NoError(t, err)
+		large, err := rules.Evaluate([]pricing.Line{{Product: product, Quantity: 21}})
+		require.NoError(t, err)
+
+		// Assert
+		require.Equal(t, domain.MoneyFromFloat(1210, ""), small.Total)
+		require.Equal(t, domain.MoneyFromFloat(1287, ""), medium.Total)
+		require.Equal(t, domain.MoneyFromFloat(2415, ""), large.Total)
+	})
+
+	t.Run("sucess should compute exact totals in minor units", func(t *testing.T) {
+		// Arrange
+		rules := pricing.DefaultRules()
+		product := domain.Product{Id: 432, CodeValue: "S83412D", Price: domain.MoneyFromFloat(898.54, "")}
+
+		// Act
+		quote, err := rules.Evaluate([]pricing.Line{{Product: product, Quantity: 1}})
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, domain.Money{Amount: 18869, Currency: "USD"}, quote.Surcharge.Amount)
+		require.Equal(t, domain.Money{Amount: 108723, Currency: "USD"}, quote.Total)
+	})
+
+	t.Run("sucess should itemize discounts, surcharge and rounding from a yaml file", func(t *testing.T) {
+		// Arrange
+		filename := writeRules(t, "rules.yaml", `
+tiers:
+  - max_items: 5
+    rate: 0.10
+  - rate: 0.05
+discounts:
+  - name: product 1 promo
+    product_id: 1
+    percent: 10
+  - name: S line
+    code_prefix: S
+    percent: 5
+rounding:
+  mode: half_up
+  decimals: 2
+`)
+		rules, err := pricing.LoadRules(filename)
+		require.NoError(t, err)
+
+		// Act
+		quote, err := rules.Evaluate([]pricing.Line{
+			{Product: domain.Product{Id: 1, CodeValue: "S1", Price: domain.MoneyFromFloat(10.005, "")}, Quantity: 2},
+			{Product: domain.Product{Id: 2, CodeValue: "T1", Price: domain.MoneyFromFloat(3.333, "")}, Quantity: 1},
+		})
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, &pricing.AppliedRule{Rule: "product 1 promo", Amount: domain.MoneyFromFloat(2, "")}, quote.Lines[0].Discount)
+		require.Equal(t, domain.MoneyFromFloat(18.02, ""), quote.Lines[0].Total)
+		require.Nil(t, quote.Lines[1].Discount)
+		require.Equal(t, domain.MoneyFromFloat(3.33, ""), quote.Lines[1].Total)
+		require.Equal(t, 3, quote.Items)
+		require.Equal(t, domain.MoneyFromFloat(21.35, ""), quote.Subtotal)
+		require.Equal(t, domain.MoneyFromFloat(23.49, ""), quote.Total)
+		require.Equal(t, "tier 1 (10%)", quote.Surcharge.Rule)
+	})
+
+	t.Run("should reject an order below the minimum", func(t *testing.T) {
+		// Arrange
+		rules, err := pricing.LoadRules(writeRules(t, "rules.json", `{"tiers": [{"rate": 0.21}], "minimum_order": 50}`))
+		require.NoError(t, err)
+
+		// Act
+		_, err = rules.Evaluate([]pricing.Line{{Product: domain.Product{Id: 1, Price: domain.MoneyFromFloat(10, "")}, Quantity: 1}})
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrMinimumOrder)
+	})
+
+	t.Run("should reject quotes whose amounts overflow", func(t *testing.T) {
+		// Arrange
+		rules := pricing.DefaultRules()
+		product := domain.Product{Id: 1, Price: domain.MoneyFromFloat(1000000, "")}
+
+		// Act
+		_, err := rules.Evaluate([]pricing.Line{{Product: product, Quantity: 1 << 40}})
+
+		// Assert
+		require.ErrorIs(t, err, domain.ErrMoneyOverflow)
+	})
+}
+
+func TestLoadRules(t *testing.T) {
+	t.Run("should reject inconsistent rules", func(t *testing.T) {
+		// Arrange
+		filename := writeRules(t, "rules.json", `{"tiers": [{"max_items": 10, "rate": 0.21}]}`)
+
+		// Act
+		_, err := pricing.LoadRules(filename)
+
+		// Assert
+		require.EqualError(t, err, "pricing rules: tier 0: the last tier cannot have max_items")
+	})
+
+	t.Run("should reject a minimum order that cannot be priced", func(t *testing.T) {
+		// Arrange
+		filename := writeRules(t, "rules.json", `{"tiers": [{"rate": 0.21}], "minimum_order": 1e300}`)
+
+		// Act
+		_, err := pricing.LoadRules(filename)
+
+		// Assert
+		require.EqualError(t, err, "pricing rules: minimum_order overflows the amounts of some currencies")
+	})
+}
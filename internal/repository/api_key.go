@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type RepositoryAPIKey interface {
+	// GetAPIKeys returns every key, revoked and expired ones included, sorted by id.
+	GetAPIKeys() ([]domain.APIKey, error)
+	GetAPIKeyById(id string) (domain.APIKey, error)
+	// CreateAPIKey stores key. It returns utility.ErrAPIKeyAlreadyExists when its id is taken.
+	CreateAPIKey(key domain.APIKey) error
+	UpdateAPIKey(key domain.APIKey) error
+}
+
+type repositoryAPIKey struct {
+	// mu guards stMap and every write to stHandler.
+	mu        sync.RWMutex
+	stMap     map[string]domain.APIKey
+	stHandler StorageAPIKey
+}
+
+// persist writes every key to stHandler, or restores id to previous when that fails. Callers must hold mu.
+func (rk *repositoryAPIKey) persist(id string, previous *domain.APIKey) error {
+	if rk.stHandler == nil {
+		return nil
+	}
+
+	if err := rk.stHandler.WriteAPIKeys(rk.sorted()); err != nil {
+		if previous == nil {
+			delete(rk.stMap, id)
+		} else {
+			rk.stMap[id] = *previous
+		}
+		return err
+	}
+
+	return nil
+}
+
+// sorted returns the keys sorted by id. Callers must hold mu.
+func (rk *repositoryAPIKey) sorted() []domain.APIKey {
+	keys := make([]domain.APIKey, 0, len(rk.stMap))
+	for _, key := range rk.stMap {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+
+	return keys
+}
+
+func (rk *repositoryAPIKey) GetAPIKeys() ([]domain.APIKey, error) {
+	rk.mu.RLock()
+	defer rk.mu.RUnlock()
+
+	return rk.sorted(), nil
+}
+
+func (rk *repositoryAPIKey) GetAPIKeyById(id string) (domain.APIKey, error) {
+	rk.mu.RLock()
+	defer rk.mu.RUnlock()
+
+	key, ok := rk.stMap[id]
+	if !ok {
+		return domain.APIKey{}, utility.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (rk *repositoryAPIKey) CreateAPIKey(key domain.APIKey) error {
+	rk.mu.Lock()
+	defer rk.mu.Unlock()
+
+	if _, ok := rk.stMap[key.Id]; ok {
+		return utility.ErrAPIKeyAlreadyExists
+	}
+	rk.stMap[key.Id] = key
+
+	return rk.persist(key.Id, nil)
+}
+
+func (rk *repositoryAPIKey) UpdateAPIKey(key domain.APIKey) error {
+	rk.mu.Lock()
+	defer rk.mu.Unlock()
+
+	previous, ok := rk.stMap[key.Id]
+	if !ok {
+		return utility.ErrAPIKeyNotFound
+	}
+	rk.stMap[key.Id] = key
+
+	return rk.persist(key.Id, &previous)
+}
+
+// NewRepositoryAPIKey creates a key repository on top of stMap, or of the keys of stHandler when stMap is nil.
+// Every change is written to stHandler when it is not nil.
+func NewRepositoryAPIKey(stMap map[string]domain.APIKey, stHandler StorageAPIKey) (*repositoryAPIKey, error) {
+	if stMap == nil {
+		stMap = map[string]domain.APIKey{}
+
+		if stHandler != nil {
+			keys, err := stHandler.GetAPIKeys()
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range keys {
+				stMap[key.Id] = key
+			}
+		}
+	}
+
+	return &repositoryAPIKey{
+		stMap:     stMap,
+		stHandler: stHandler,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type StorageAPIKey interface {
+	// GetAPIKeys returns the persisted keys, or none when nothing was persisted yet.
+	GetAPIKeys() ([]domain.APIKey, error)
+	// WriteAPIKeys atomically replaces the persisted keys with keys.
+	WriteAPIKeys(keys []domain.APIKey) error
+}
+
+type storageAPIKey struct {
+	filename string
+}
+
+func (sk *storageAPIKey) GetAPIKeys() ([]domain.APIKey, error) {
+	data, err := os.ReadFile(sk.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "read api keys", err)
+	}
+
+	var keys []domain.APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, utility.NewStorageError(utility.ErrCorruptData, "read api keys", err)
+	}
+
+	return keys, nil
+}
+
+func (sk *storageAPIKey) WriteAPIKeys(keys []domain.APIKey) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(keys); err != nil {
+		return utility.NewStorageError(utility.ErrCorruptData, "encode api keys", err)
+	}
+
+	if err := writeFileAtomic(sk.filename, buf.Bytes()); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "write api keys", err)
+	}
+
+	return nil
+}
+
+func NewStorageAPIKey(filename string) *storageAPIKey {
+	return &storageAPIKey{
+		filename: filename,
+	}
+}
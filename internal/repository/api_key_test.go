@@ -0,0 +1,53 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryAPIKey(t *testing.T) {
+	newKey := func(id string) domain.APIKey {
+		return domain.APIKey{Id: id, Name: "key " + id, Hash: "0123", Scopes: []string{"catalog:read"}, CreatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	}
+
+	t.Run("sucess should read back the keys written to the storage", func(t *testing.T) {
+		// Arrange
+		filename := t.TempDir() + "/api_keys.json"
+		rk, err := repository.NewRepositoryAPIKey(nil, repository.NewStorageAPIKey(filename))
+		require.NoError(t, err)
+		require.NoError(t, rk.CreateAPIKey(newKey("b")))
+		require.NoError(t, rk.CreateAPIKey(newKey("a")))
+
+		revoked := newKey("b")
+		revokedAt := revoked.CreatedAt.Add(time.Hour)
+		revoked.RevokedAt = &revokedAt
+		require.NoError(t, rk.UpdateAPIKey(revoked))
+
+		// Act
+		reloaded, err := repository.NewRepositoryAPIKey(nil, repository.NewStorageAPIKey(filename))
+		require.NoError(t, err)
+		keys, err := reloaded.GetAPIKeys()
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, []domain.APIKey{newKey("a"), revoked}, keys)
+	})
+
+	t.Run("should not create a key twice", func(t *testing.T) {
+		// Arrange
+		rk, err := repository.NewRepositoryAPIKey(nil, nil)
+		require.NoError(t, err)
+		require.NoError(t, rk.CreateAPIKey(newKey("a")))
+
+		// Act
+		err = rk.CreateAPIKey(newKey("a"))
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrAPIKeyAlreadyExists)
+	})
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type RepositoryCart interface {
+	GetCartById(id int) (domain.Cart, error)
+	// CreateCart stores cart with a new id and returns it.
+	CreateCart(cart domain.Cart) (domain.Cart, error)
+	// UpdateCart atomically applies fn to the cart of id. Nothing is changed when fn returns an error.
+	UpdateCart(id int, fn func(cart *domain.Cart) error) (domain.Cart, error)
+	DeleteCart(id int) error
+	// DeleteCartsUpdatedBefore deletes the carts last changed before t and returns how many it deleted.
+	DeleteCartsUpdatedBefore(t time.Time) (int, error)
+}
+
+// repositoryCart keeps carts in memory only: they are short lived and are abandoned on restart.
+type repositoryCart struct {
+	mu    sync.RWMutex
+	stMap map[int]domain.Cart
+	idGen IdGenerator
+}
+
+// copyCart returns cart with its own copy of the items, so callers cannot change the stored cart.
+func copyCart(cart domain.Cart) domain.Cart {
+	cart.Items = append([]domain.CartItem{}, cart.Items...)
+	return cart
+}
+
+func (rc *repositoryCart) GetCartById(id int) (domain.Cart, error) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	cart, ok := rc.stMap[id]
+	if !ok {
+		return domain.Cart{}, utility.ErrCartNotFound
+	}
+
+	return copyCart(cart), nil
+}
+
+func (rc *repositoryCart) CreateCart(cart domain.Cart) (domain.Cart, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	cart = copyCart(cart)
+	cart.Id = rc.idGen.Next()
+	rc.stMap[cart.Id] = cart
+
+	return copyCart(cart), nil
+}
+
+func (rc *repositoryCart) UpdateCart(id int, fn func(cart *domain.Cart) error) (domain.Cart, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stored, ok := rc.stMap[id]
+	if !ok {
+		return domain.Cart{}, utility.ErrCartNotFound
+	}
+
+	cart := copyCart(stored)
+	if err := fn(&cart); err != nil {
+		return domain.Cart{}, err
+	}
+	cart.Id = id
+	rc.stMap[id] = cart
+
+	return copyCart(cart), nil
+}
+
+func (rc *repositoryCart) DeleteCart(id int) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if _, ok := rc.stMap[id]; !ok {
+		return utility.ErrCartNotFound
+	}
+	delete(rc.stMap, id)
+
+	return nil
+}
+
+func (rc *repositoryCart) DeleteCartsUpdatedBefore(t time.Time) (int, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	deleted := 0
+	for id, cart := range rc.stMap {
+		if cart.UpdatedAt.Before(t) {
+			delete(rc.stMap, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func NewRepositoryCart() *repositoryCart {
+	return &repositoryCart{
+		stMap: map[int]domain.Cart{},
+		idGen: NewSequentialIdGenerator(0),
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import "sync/atomic"
+
+// IdGenerator allocates product ids. Implementations must be safe for concurrent use
+// and must never hand out the same id twice, even after the product holding it is deleted.
+type IdGenerator interface {
+	// Next returns a new, never used id.
+	Next() int
+	// Observe records that id is in use so it is never returned by Next.
+	Observe(id int)
+	// Last returns the highest id returned by Next or passed to Observe.
+	Last() int
+}
+
+type sequentialIdGenerator struct {
+	last atomic.Int64
+}
+
+func (g *sequentialIdGenerator) Next() int {
+	return int(g.last.Add(1))
+}
+
+func (g *sequentialIdGenerator) Observe(id int) {
+	for {
+		last := g.last.Load()
+		if int64(id) <= last || g.last.CompareAndSwap(last, int64(id)) {
+			return
+		}
+	}
+}
+
+func (g *sequentialIdGenerator) Last() int {
+	return int(g.last.Load())
+}
+
+// NewSequentialIdGenerator returns an IdGenerator handing out last+1, last+2, ...
+func NewSequentialIdGenerator(last int) *sequentialIdGenerator {
+	g := &sequentialIdGenerator{}
+	g.last.Store(int64(last))
+	return g
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type RepositoryOrder interface {
+	GetOrderById(id int) (domain.Order, error)
+	// CreateOrder stores order with a new id and returns it.
+	CreateOrder(order domain.Order) (domain.Order, error)
+	// CancelOrder atomically marks a placed order as cancelled at at. It returns utility.ErrOrderCancelled
+	// when the order was already cancelled, so concurrent cancels restock an order only once.
+	CancelOrder(id int, at time.Time) (domain.Order, error)
+	UpdateOrder(order domain.Order) error
+}
+
+type repositoryOrder struct {
+	// mu guards stMap and every write to stHandler.
+	mu        sync.RWMutex
+	stMap     map[int]domain.Order
+	stHandler StorageOrder
+	idGen     IdGenerator
+}
+
+// persist appends the order of id to the log of stHandler, or restores id to previous when that fails.
+// Callers must hold mu.
+func (ro *repositoryOrder) persist(id int, previous *domain.Order) error {
+	if ro.stHandler == nil {
+		return nil
+	}
+
+	if err := ro.stHandler.AppendOrder(ro.stMap[id]); err != nil {
+		if previous == nil {
+			delete(ro.stMap, id)
+		} else {
+			ro.stMap[id] = *previous
+		}
+		return err
+	}
+
+	// The order is already durable in the log, a failed compaction is retried on the next write.
+	if ro.stHandler.NeedsCompaction() {
+		if err := ro.compact(); err != nil {
+			log.Printf("unable to compact orders storage: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// compact folds the write-ahead log into the snapshot. Callers must hold mu.
+func (ro *repositoryOrder) compact() error {
+	orders := make([]domain.Order, 0, len(ro.stMap))
+	for _, order := range ro.stMap {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+
+	return ro.stHandler.WriteOrders(orders)
+}
+
+func (ro *repositoryOrder) GetOrderById(id int) (domain.Order, error) {
+	ro.mu.RLock()
+	defer ro.mu.RUnlock()
+
+	order, ok := ro.stMap[id]
+	if !ok {
+		return domain.Order{}, utility.ErrOrderNotFound
+	}
+
+	return order, nil
+}
+
+func (ro *repositoryOrder) CreateOrder(order domain.Order) (domain.Order, error) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	order.Id = ro.idGen.Next()
+	ro.stMap[order.Id] = order
+
+	if err := ro.persist(order.Id, nil); err != nil {
+		return domain.Order{}, err
+	}
+
+	return order, nil
+}
+
+func (ro *repositoryOrder) UpdateOrder(order domain.Order) error {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	previous, ok := ro.stMap[order.Id]
+	if !ok {
+		return utility.ErrOrderNotFound
+	}
+	ro.stMap[order.Id] = order
+
+	return ro.persist(order.Id, &previous)
+}
+
+func (ro *repositoryOrder) CancelOrder(id int, at time.Time) (domain.Order, error) {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	previous, ok := ro.stMap[id]
+	if !ok {
+		return domain.Order{}, utility.ErrOrderNotFound
+	}
+	if previous.Status == domain.OrderStatusCancelled {
+		return domain.Order{}, utility.ErrOrderCancelled
+	}
+
+	order := previous
+	order.Status = domain.OrderStatusCancelled
+	order.CancelledAt = &at
+	ro.stMap[id] = order
+
+	if err := ro.persist(id, &previous); err != nil {
+		return domain.Order{}, err
+	}
+
+	return order, nil
+}
+
+// NewRepositoryOrder creates an order repository on top of stMap, or of the orders of stHandler when stMap is nil.
+// Every change is written to stHandler when it is not nil.
+func NewRepositoryOrder(stMap map[int]domain.Order, stHandler StorageOrder) (*repositoryOrder, error) {
+	if stMap == nil {
+		stMap = map[int]domain.Order{}
+
+		if stHandler != nil {
+			orders, err := stHandler.GetOrders()
+			if err != nil {
+				return nil, err
+			}
+			for _, order := range orders {
+				stMap[order.Id] = order
+			}
+		}
+	}
+
+	// Orders are never deleted, so the highest id is the last one used.
+	idGen := NewSequentialIdGenerator(0)
+	for id := range stMap {
+		idGen.Observe(id)
+	}
+
+	return &repositoryOrder{
+		stMap:     stMap,
+		stHandler: stHandler,
+		idGen:     idGen,
+	}, nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// The lines of an order are only ever read with their order, so they are kept as a JSON column.
+const sqliteOrderSchema = `
+CREATE TABLE IF NOT EXISTS orders (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	status       TEXT    NOT NULL,
+	lines        TEXT    NOT NULL,
+	total        INTEGER NOT NULL,
+	currency     TEXT    NOT NULL,
+	created_at   TIMESTAMP NOT NULL,
+	cancelled_at TIMESTAMP
+);
+`
+
+const sqliteOrderColumns = "id, status, lines, total, currency, created_at, cancelled_at"
+
+type repositoryOrderSQLite struct {
+	db *sql.DB
+}
+
+func scanOrder(row rowScanner) (domain.Order, error) {
+	var order domain.Order
+	var lines string
+	var cancelledAt sql.NullTime
+	err := row.Scan(
+		&order.Id,
+		&order.Status,
+		&lines,
+		&order.Total.Amount,
+		&order.Total.Currency,
+		&order.CreatedAt,
+		&cancelledAt,
+	)
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	if err := json.Unmarshal([]byte(lines), &order.Lines); err != nil {
+		return domain.Order{}, utility.NewStorageError(utility.ErrCorruptData, "read order", err)
+	}
+	if cancelledAt.Valid {
+		order.CancelledAt = &cancelledAt.Time
+	}
+
+	return order, nil
+}
+
+// orderArgs returns the column values of order, except its id.
+func orderArgs(order domain.Order) ([]any, error) {
+	lines, err := json.Marshal(order.Lines)
+	if err != nil {
+		return nil, utility.NewStorageError(utility.ErrCorruptData, "encode order", err)
+	}
+
+	var cancelledAt sql.NullTime
+	if order.CancelledAt != nil {
+		cancelledAt = sql.NullTime{Time: order.CancelledAt.UTC(), Valid: true}
+	}
+
+	return []any{
+		order.Status,
+		string(lines),
+		order.Total.Amount,
+		order.Total.Currency,
+		order.CreatedAt.UTC(),
+		cancelledAt,
+	}, nil
+}
+
+func (ro *repositoryOrderSQLite) GetOrderById(id int) (domain.Order, error) {
+	row := ro.db.QueryRow("SELECT "+sqliteOrderColumns+" FROM orders WHERE id = ?", id)
+
+	order, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Order{}, utility.ErrOrderNotFound
+	}
+	if err != nil {
+		return domain.Order{}, mapSQLiteError("read order", err)
+	}
+
+	return order, nil
+}
+
+func (ro *repositoryOrderSQLite) CreateOrder(order domain.Order) (domain.Order, error) {
+	args, err := orderArgs(order)
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	result, err := ro.db.Exec("INSERT INTO orders (status, lines, total, currency, created_at, cancelled_at) VALUES (?, ?, ?, ?, ?, ?)", args...)
+	if err != nil {
+		return domain.Order{}, mapSQLiteError("create order", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return domain.Order{}, mapSQLiteError("create order", err)
+	}
+	order.Id = int(id)
+
+	return order, nil
+}
+
+func (ro *repositoryOrderSQLite) UpdateOrder(order domain.Order) error {
+	args, err := orderArgs(order)
+	if err != nil {
+		return err
+	}
+
+	result, err := ro.db.Exec(
+		"UPDATE orders SET status = ?, lines = ?, total = ?, currency = ?, created_at = ?, cancelled_at = ? WHERE id = ?",
+		append(args, order.Id)...,
+	)
+	if err != nil {
+		return mapSQLiteError("update order", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return mapSQLiteError("update order", err)
+	}
+	if affected == 0 {
+		return utility.ErrOrderNotFound
+	}
+
+	return nil
+}
+
+func (ro *repositoryOrderSQLite) CancelOrder(id int, at time.Time) (domain.Order, error) {
+	result, err := ro.db.Exec(
+		"UPDATE orders SET status = ?, cancelled_at = ? WHERE id = ? AND status <> ?",
+		domain.OrderStatusCancelled,
+		at.UTC(),
+		id,
+		domain.OrderStatusCancelled,
+	)
+	if err != nil {
+		return domain.Order{}, mapSQLiteError("cancel order", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return domain.Order{}, mapSQLiteError("cancel order", err)
+	}
+
+	order, err := ro.GetOrderById(id)
+	if err != nil {
+		return domain.Order{}, err
+	}
+	if affected == 0 {
+		return domain.Order{}, utility.ErrOrderCancelled
+	}
+
+	return order, nil
+}
+
+// NewRepositoryOrderSQLite creates the orders schema on db if needed.
+func NewRepositoryOrderSQLite(db *sql.DB) (*repositoryOrderSQLite, error) {
+	if db == nil {
+		return nil, errors.New("db cannot be nil")
+	}
+
+	if _, err := db.Exec(sqliteOrderSchema); err != nil {
+		return nil, err
+	}
+
+	return &repositoryOrderSQLite{db: db}, nil
+}
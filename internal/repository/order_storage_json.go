@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type StorageOrder interface {
+	// GetOrders returns the persisted orders, with every logged change applied, or none when nothing was persisted yet.
+	GetOrders() ([]domain.Order, error)
+	// WriteOrders atomically replaces the snapshot with orders and discards the log.
+	WriteOrders(orders []domain.Order) error
+	// AppendOrder durably records a new or changed order in the write-ahead log.
+	AppendOrder(order domain.Order) error
+	// NeedsCompaction reports whether the log has grown enough to be folded into the snapshot with WriteOrders.
+	NeedsCompaction() bool
+}
+
+type storageOrder struct {
+	filename string
+	// compactEvery is the number of log records after which NeedsCompaction reports true.
+	compactEvery int
+	// logRecords is the number of records currently in the log.
+	logRecords int
+}
+
+// logFilename is the write-ahead log file, next to the orders file.
+func (so *storageOrder) logFilename() string {
+	return so.filename + ".wal"
+}
+
+func (so *storageOrder) GetOrders() ([]domain.Order, error) {
+	var orders []domain.Order
+
+	data, err := os.ReadFile(so.filename)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "read orders", err)
+	default:
+		if err := json.Unmarshal(data, &orders); err != nil {
+			return nil, utility.NewStorageError(utility.ErrCorruptData, "read orders", err)
+		}
+	}
+
+	records, err := readLogRecords[domain.Order](so.logFilename())
+	if err != nil {
+		return nil, err
+	}
+	so.logRecords = len(records)
+	if len(records) == 0 {
+		return orders, nil
+	}
+
+	// Each record is the whole order after a change, so the last record of an order wins.
+	ordersMap := make(map[int]domain.Order, len(orders)+len(records))
+	for _, order := range orders {
+		ordersMap[order.Id] = order
+	}
+	for _, order := range records {
+		ordersMap[order.Id] = order
+	}
+
+	orders = make([]domain.Order, 0, len(ordersMap))
+	for _, order := range ordersMap {
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+
+	return orders, nil
+}
+
+func (so *storageOrder) WriteOrders(orders []domain.Order) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(orders); err != nil {
+		return utility.NewStorageError(utility.ErrCorruptData, "encode orders", err)
+	}
+
+	if err := writeFileAtomic(so.filename, buf.Bytes()); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "write orders", err)
+	}
+
+	// The snapshot now holds every logged change.
+	if err := os.Truncate(so.logFilename(), 0); err != nil && !os.IsNotExist(err) {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "truncate log", err)
+	}
+	so.logRecords = 0
+
+	return nil
+}
+
+func (so *storageOrder) AppendOrder(order domain.Order) error {
+	if err := appendLogRecord(so.logFilename(), order); err != nil {
+		return err
+	}
+
+	so.logRecords++
+	return nil
+}
+
+func (so *storageOrder) NeedsCompaction() bool {
+	return so.logRecords >= so.compactEvery
+}
+
+func NewStorageOrder(filename string) *storageOrder {
+	return &storageOrder{
+		filename:     filename,
+		compactEvery: defaultCompactEvery,
+	}
+}
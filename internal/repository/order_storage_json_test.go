@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOrder() domain.Order {
+	return domain.Order{
+		Status:    domain.OrderStatusPlaced,
+		Lines:     []domain.OrderLine{{ProductId: 1, Quantity: 2, UnitPrice: domain.MoneyFromFloat(10, ""), Total: domain.MoneyFromFloat(20, "")}},
+		Total:     domain.MoneyFromFloat(24.2, ""),
+		CreatedAt: time.Date(2023, time.January, 10, 15, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestStorageOrderLog(t *testing.T) {
+	t.Run("sucess should append orders to the log and replay them on load", func(t *testing.T) {
+		// Arrange
+		storage := NewStorageOrder(t.TempDir() + "/orders.json")
+		ro, err := NewRepositoryOrder(nil, storage)
+		require.NoError(t, err)
+		at := time.Date(2023, time.January, 11, 9, 0, 0, 0, time.UTC)
+
+		first, err := ro.CreateOrder(newTestOrder())
+		require.NoError(t, err)
+		second, err := ro.CreateOrder(newTestOrder())
+		require.NoError(t, err)
+		cancelled, err := ro.CancelOrder(first.Id, at)
+		require.NoError(t, err)
+
+		// Act
+		orders, err := NewStorageOrder(storage.filename).GetOrders()
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, []domain.Order{cancelled, second}, orders)
+
+		_, err = os.Stat(storage.filename)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should fold the log into the snapshot once it is large enough", func(t *testing.T) {
+		// Arrange
+		storage := NewStorageOrder(t.TempDir() + "/orders.json")
+		storage.compactEvery = 2
+		ro, err := NewRepositoryOrder(nil, storage)
+		require.NoError(t, err)
+
+		// Act
+		_, err = ro.CreateOrder(newTestOrder())
+		require.NoError(t, err)
+		_, err = ro.CreateOrder(newTestOrder())
+		require.NoError(t, err)
+
+		// Assert
+		info, err := os.Stat(storage.logFilename())
+		require.NoError(t, err)
+		require.Zero(t, info.Size())
+
+		orders, err := NewStorageOrder(storage.filename).GetOrders()
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+	})
+}
@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func newOrder() domain.Order {
+	return domain.Order{
+		Status:    domain.OrderStatusPlaced,
+		Lines:     []domain.OrderLine{{ProductId: 1, Quantity: 2, UnitPrice: domain.MoneyFromFloat(10, ""), Total: domain.MoneyFromFloat(20, "")}},
+		Total:     domain.MoneyFromFloat(24.2, ""),
+		CreatedAt: time.Date(2023, time.January, 10, 15, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRepositoryOrder(t *testing.T) {
+	newRepositories := map[string]func(t *testing.T) repository.RepositoryOrder{
+		"json": func(t *testing.T) repository.RepositoryOrder {
+			ro, err := repository.NewRepositoryOrder(nil, repository.NewStorageOrder(t.TempDir()+"/orders.json"))
+			require.NoError(t, err)
+			return ro
+		},
+		"sqlite": func(t *testing.T) repository.RepositoryOrder {
+			ro, err := repository.NewRepositoryOrderSQLite(newTestDB(t))
+			require.NoError(t, err)
+			return ro
+		},
+	}
+
+	for name, newRepository := range newRepositories {
+		t.Run(name+" sucess should store an order and read it back", func(t *testing.T) {
+			// Arrange
+			ro := newRepository(t)
+
+			// Act
+			created, err := ro.CreateOrder(newOrder())
+			require.NoError(t, err)
+			order, err := ro.GetOrderById(created.Id)
+
+			// Assert
+			require.NoError(t, err)
+			require.Equal(t, 1, created.Id)
+			require.Equal(t, created, order)
+		})
+
+		t.Run(name+" should cancel an order only once", func(t *testing.T) {
+			// Arrange
+			ro := newRepository(t)
+			created, err := ro.CreateOrder(newOrder())
+			require.NoError(t, err)
+			at := time.Date(2023, time.January, 11, 9, 0, 0, 0, time.UTC)
+
+			// Act
+			cancelled, err := ro.CancelOrder(created.Id, at)
+			require.NoError(t, err)
+			_, errAgain := ro.CancelOrder(created.Id, at)
+
+			// Assert
+			require.Equal(t, domain.OrderStatusCancelled, cancelled.Status)
+			require.True(t, at.Equal(*cancelled.CancelledAt))
+			require.ErrorIs(t, errAgain, utility.ErrOrderCancelled)
+		})
+
+		t.Run(name+" should return an error when the order does not exist", func(t *testing.T) {
+			// Arrange
+			ro := newRepository(t)
+
+			// Act
+			_, err := ro.GetOrderById(1)
+
+			// Assert
+			require.ErrorIs(t, err, utility.ErrOrderNotFound)
+		})
+	}
+}
+
+func TestRepositoryOrderReload(t *testing.T) {
+	t.Run("sucess should read back the orders written to the storage", func(t *testing.T) {
+		// Arrange
+		filename := t.TempDir() + "/orders.json"
+		ro, err := repository.NewRepositoryOrder(nil, repository.NewStorageOrder(filename))
+		require.NoError(t, err)
+		created, err := ro.CreateOrder(newOrder())
+		require.NoError(t, err)
+
+		// Act
+		reloaded, err := repository.NewRepositoryOrder(nil, repository.NewStorageOrder(filename))
+		require.NoError(t, err)
+		order, err := reloaded.GetOrderById(created.Id)
+		require.NoError(t, err)
+		next, err := reloaded.CreateOrder(newOrder())
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, created, order)
+		require.Equal(t, 2, next.Id)
+	})
+}
@@ -1,11 +1,17 @@
 package repository
 
 import (
+	"errors"
+	"log"
+	"sort"
+	"sync"
+
 	"github.com/MDavidCV/go-web-module/internal/domain"
 	"github.com/MDavidCV/go-web-module/utility"
 )
 
-type RepositoryProduct interface {
+// RepositoryProductTx is the set of product operations that can be grouped in a single transaction.
+type RepositoryProductTx interface {
 	GetProducts() ([]domain.Product, error)
 	GetProductById(id int) (domain.Product, error)
 	CreateProduct(product utility.ProductRequest) (domain.Product, error)
@@ -14,22 +20,233 @@ type RepositoryProduct interface {
 	UpdatePatchProduct(int, utility.ProductPatchRequest) (domain.Product, error)
 }
 
+type RepositoryProduct interface {
+	RepositoryProductTx
+	// IterateProducts calls fn with every product in id order, stopping at the first error of fn, which it returns.
+	// Products are read in small batches and no lock is held while fn runs, so a product written during the
+	// iteration is seen with its new value only if its batch was not read yet.
+	IterateProducts(fn func(product domain.Product) error) error
+	// WithTx runs fn atomically: no other write is interleaved with it, and every change made through tx
+	// is discarded when fn returns an error. fn must not call the repository itself, only tx.
+	WithTx(fn func(tx RepositoryProductTx) error) error
+}
+
 type repositoryProduct struct {
+	// mu guards stMap and every write to stHandler.
+	mu        sync.RWMutex
 	stMap     map[int]domain.Product
 	stHandler StorageProduct
+	idGen     IdGenerator
+}
+
+func (rp *repositoryProduct) WithTx(fn func(tx RepositoryProductTx) error) error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+
+	tx := &productTx{
+		stMap: rp.stMap,
+		undo:  map[int]*domain.Product{},
+		idGen: rp.idGen,
+	}
+
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	if len(tx.undo) > 0 && rp.stHandler != nil {
+		put, deleted := tx.changes()
+		if err := rp.stHandler.AppendChanges(put, deleted, rp.idGen.Last()); err != nil {
+			tx.rollback()
+			return err
+		}
+
+		// The changes are already durable in the log, a failed compaction is retried on the next write.
+		if rp.stHandler.NeedsCompaction() {
+			if err := rp.compact(); err != nil {
+				log.Printf("unable to compact products storage: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compact folds the write-ahead log into the snapshot. Callers must hold mu.
+func (rp *repositoryProduct) compact() error {
+	// The sequence is written first: WriteProducts discards the log, which also holds it.
+	if err := rp.stHandler.WriteLastId(rp.idGen.Last()); err != nil {
+		return err
+	}
+
+	return rp.stHandler.WriteProducts(rp.stMap)
 }
 
 func (rp *repositoryProduct) GetProducts() ([]domain.Product, error) {
-	products := make([]domain.Product, 0, len(rp.stMap))
-	for _, product := range rp.stMap {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+
+	return (&productTx{stMap: rp.stMap}).GetProducts()
+}
+
+// iterateBatchSize is the number of products IterateProducts reads at a time.
+const iterateBatchSize = 500
+
+func (rp *repositoryProduct) IterateProducts(fn func(product domain.Product) error) error {
+	rp.mu.RLock()
+	ids := make([]int, 0, len(rp.stMap))
+	for id := range rp.stMap {
+		ids = append(ids, id)
+	}
+	rp.mu.RUnlock()
+
+	sort.Ints(ids)
+
+	batch := make([]domain.Product, 0, iterateBatchSize)
+	for start := 0; start < len(ids); start += iterateBatchSize {
+		end := min(start+iterateBatchSize, len(ids))
+
+		// Products deleted since the ids were read are skipped.
+		batch = batch[:0]
+		rp.mu.RLock()
+		for _, id := range ids[start:end] {
+			if product, ok := rp.stMap[id]; ok {
+				batch = append(batch, product)
+			}
+		}
+		rp.mu.RUnlock()
+
+		for _, product := range batch {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (rp *repositoryProduct) GetProductById(id int) (domain.Product, error) {
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+
+	return (&productTx{stMap: rp.stMap}).GetProductById(id)
+}
+
+func (rp *repositoryProduct) CreateProduct(reqProduct utility.ProductRequest) (product domain.Product, err error) {
+	err = rp.WithTx(func(tx RepositoryProductTx) error {
+		product, err = tx.CreateProduct(reqProduct)
+		return err
+	})
+	return product, err
+}
+
+func (rp *repositoryProduct) UpdateProduct(id int, reqProduct utility.ProductRequest) (product domain.Product, err error) {
+	err = rp.WithTx(func(tx RepositoryProductTx) error {
+		product, err = tx.UpdateProduct(id, reqProduct)
+		return err
+	})
+	return product, err
+}
+
+func (rp *repositoryProduct) DeleteProduct(id int) error {
+	return rp.WithTx(func(tx RepositoryProductTx) error {
+		return tx.DeleteProduct(id)
+	})
+}
+
+func (rp *repositoryProduct) UpdatePatchProduct(id int, reqProduct utility.ProductPatchRequest) (product domain.Product, err error) {
+	err = rp.WithTx(func(tx RepositoryProductTx) error {
+		product, err = tx.UpdatePatchProduct(id, reqProduct)
+		return err
+	})
+	return product, err
+}
+
+// productTx operates directly on the repository map while its lock is held,
+// remembering the previous value of every touched id so the changes can be rolled back.
+type productTx struct {
+	stMap map[int]domain.Product
+	// undo holds the value of each touched id before the transaction, nil when the id did not exist.
+	undo  map[int]*domain.Product
+	idGen IdGenerator
+}
+
+func (tx *productTx) remember(id int) {
+	if _, ok := tx.undo[id]; ok {
+		return
+	}
+
+	if product, ok := tx.stMap[id]; ok {
+		tx.undo[id] = &product
+	} else {
+		tx.undo[id] = nil
+	}
+}
+
+// save stores product with its version incremented, so a new product gets version 1, and returns it.
+func (tx *productTx) save(product domain.Product) domain.Product {
+	tx.remember(product.Id)
+	product.Version++
+	tx.stMap[product.Id] = product
+	return product
+}
+
+func (tx *productTx) remove(id int) {
+	tx.remember(id)
+	delete(tx.stMap, id)
+}
+
+// changes returns the products written and the ids deleted by the transaction, ordered by id.
+func (tx *productTx) changes() (put []domain.Product, deleted []int) {
+	ids := make([]int, 0, len(tx.undo))
+	for id := range tx.undo {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if product, ok := tx.stMap[id]; ok {
+			put = append(put, product)
+		} else if tx.undo[id] != nil {
+			deleted = append(deleted, id)
+		}
+	}
+
+	return put, deleted
+}
+
+func (tx *productTx) rollback() {
+	for id, product := range tx.undo {
+		if product == nil {
+			delete(tx.stMap, id)
+		} else {
+			tx.stMap[id] = *product
+		}
+	}
+}
+
+// codeValueTaken reports whether a product other than id already uses codeValue.
+func (tx *productTx) codeValueTaken(codeValue string, id int) bool {
+	for _, product := range tx.stMap {
+		if product.CodeValue == codeValue && product.Id != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *productTx) GetProducts() ([]domain.Product, error) {
+	products := make([]domain.Product, 0, len(tx.stMap))
+	for _, product := range tx.stMap {
 		products = append(products, product)
 	}
 
 	return products, nil
 }
 
-func (rp *repositoryProduct) GetProductById(id int) (domain.Product, error) {
-	product, ok := rp.stMap[id]
+func (tx *productTx) GetProductById(id int) (domain.Product, error) {
+	product, ok := tx.stMap[id]
 
 	if !ok {
 		return domain.Product{}, utility.ErrProductNotFound
@@ -38,8 +255,12 @@ func (rp *repositoryProduct) GetProductById(id int) (domain.Product, error) {
 	return product, nil
 }
 
-func (rp *repositoryProduct) CreateProduct(reqProduct utility.ProductRequest) (domain.Product, error) {
-	id := len(rp.stMap) + 1
+func (tx *productTx) CreateProduct(reqProduct utility.ProductRequest) (domain.Product, error) {
+	if tx.codeValueTaken(reqProduct.CodeValue, 0) {
+		return domain.Product{}, utility.ErrUniqueCodeValue
+	}
+
+	id := tx.idGen.Next()
 	product := domain.Product{
 		Id:          id,
 		Name:        reqProduct.Name,
@@ -50,27 +271,24 @@ func (rp *repositoryProduct) CreateProduct(reqProduct utility.ProductRequest) (d
 		Price:       reqProduct.Price,
 	}
 
-	if _, ok := rp.stMap[id]; ok {
+	if _, ok := tx.stMap[id]; ok {
 		return domain.Product{}, utility.ErrProductAlreadyExists
 	}
 
-	rp.stMap[id] = product
-	if rp.stHandler != nil {
-		if err := rp.stHandler.WriteProducts(rp.stMap); err != nil {
-			panic(err)
-		}
-	}
-
-	return product, nil
+	return tx.save(product), nil
 }
 
-func (rp *repositoryProduct) UpdateProduct(id int, reqProduct utility.ProductRequest) (domain.Product, error) {
-	product, ok := rp.stMap[id]
+func (tx *productTx) UpdateProduct(id int, reqProduct utility.ProductRequest) (domain.Product, error) {
+	product, ok := tx.stMap[id]
 
 	if !ok {
 		return domain.Product{}, utility.ErrProductNotFound
 	}
 
+	if tx.codeValueTaken(reqProduct.CodeValue, id) {
+		return domain.Product{}, utility.ErrUniqueCodeValue
+	}
+
 	product.Name = reqProduct.Name
 	product.Quantity = reqProduct.Quantity
 	product.CodeValue = reqProduct.CodeValue
@@ -78,36 +296,30 @@ func (rp *repositoryProduct) UpdateProduct(id int, reqProduct utility.ProductReq
 	product.Expiration = reqProduct.Expiration
 	product.Price = reqProduct.Price
 
-	rp.stMap[id] = product
-	if err := rp.stHandler.WriteProducts(rp.stMap); err != nil {
-		panic(err)
-	}
-
-	return product, nil
+	return tx.save(product), nil
 }
 
-func (rp *repositoryProduct) DeleteProduct(id int) error {
-	if _, ok := rp.stMap[id]; !ok {
+func (tx *productTx) DeleteProduct(id int) error {
+	if _, ok := tx.stMap[id]; !ok {
 		return utility.ErrProductNotFound
 	}
 
-	delete(rp.stMap, id)
-	if rp.stHandler != nil {
-		if err := rp.stHandler.WriteProducts(rp.stMap); err != nil {
-			panic(err)
-		}
-	}
+	tx.remove(id)
 
 	return nil
 }
 
-func (rp *repositoryProduct) UpdatePatchProduct(id int, reqProduct utility.ProductPatchRequest) (domain.Product, error) {
-	product, ok := rp.stMap[id]
+func (tx *productTx) UpdatePatchProduct(id int, reqProduct utility.ProductPatchRequest) (domain.Product, error) {
+	product, ok := tx.stMap[id]
 
 	if !ok {
 		return domain.Product{}, utility.ErrProductNotFound
 	}
 
+	if reqProduct.CodeValue != nil && tx.codeValueTaken(*reqProduct.CodeValue, id) {
+		return domain.Product{}, utility.ErrUniqueCodeValue
+	}
+
 	if reqProduct.Name != nil {
 		product.Name = *reqProduct.Name
 	}
@@ -132,26 +344,20 @@ func (rp *repositoryProduct) UpdatePatchProduct(id int, reqProduct utility.Produ
 		product.Price = *reqProduct.Price
 	}
 
-	rp.stMap[id] = product
-	if rp.stHandler != nil {
-		if err := rp.stHandler.WriteProducts(rp.stMap); err != nil {
-			panic(err)
-		}
-	}
-
-	return product, nil
+	return tx.save(product), nil
 }
 
-func NewRepositoryProduct(stMap map[int]domain.Product, stHandler StorageProduct) *repositoryProduct {
+// NewRepositoryProduct stores the products in stMap, or loads them from stHandler when stMap is nil.
+func NewRepositoryProduct(stMap map[int]domain.Product, stHandler StorageProduct) (*repositoryProduct, error) {
 
 	if stMap == nil && stHandler == nil {
-		panic("stMap and stHandler cannot be nil at the same time")
+		return nil, errors.New("stMap and stHandler cannot be nil at the same time")
 	}
 
 	if stMap == nil && stHandler != nil {
 		st, err := stHandler.GetProducts()
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
 		stMap = make(map[int]domain.Product, len(st))
@@ -160,8 +366,22 @@ func NewRepositoryProduct(stMap map[int]domain.Product, stHandler StorageProduct
 		}
 	}
 
+	// Seed the sequence with the highest id ever used, so ids of deleted products are not reused.
+	idGen := NewSequentialIdGenerator(0)
+	for id := range stMap {
+		idGen.Observe(id)
+	}
+	if stHandler != nil {
+		lastId, err := stHandler.GetLastId()
+		if err != nil {
+			return nil, err
+		}
+		idGen.Observe(lastId)
+	}
+
 	return &repositoryProduct{
 		stMap:     stMap,
 		stHandler: stHandler,
-	}
+		idGen:     idGen,
+	}, nil
 }
@@ -0,0 +1,490 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS products (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT    NOT NULL,
+	quantity     INTEGER NOT NULL,
+	code_value   TEXT    NOT NULL,
+	is_published BOOLEAN NOT NULL DEFAULT 0,
+	expiration   TEXT    NOT NULL,
+	price        INTEGER NOT NULL,
+	currency     TEXT    NOT NULL,
+	version      INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_code_value ON products (code_value);
+`
+
+// sqliteProductColumns stores the price in minor units, next to its currency.
+const sqliteProductColumns = "id, name, quantity, code_value, is_published, expiration, price, currency, version"
+
+type sqlQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqliteProductTx implements the product operations on top of either the database or an open transaction.
+type sqliteProductTx struct {
+	q     sqlQuerier
+	idGen IdGenerator
+}
+
+type repositoryProductSQLite struct {
+	sqliteProductTx
+	db *sql.DB
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row rowScanner) (domain.Product, error) {
+	var product domain.Product
+	err := row.Scan(
+		&product.Id,
+		&product.Name,
+		&product.Quantity,
+		&product.CodeValue,
+		&product.IsPublished,
+		&product.Expiration,
+		&product.Price.Amount,
+		&product.Price.Currency,
+		&product.Version,
+	)
+	return product, err
+}
+
+// mapSQLiteError translates driver errors into the errors the service layer understands.
+func mapSQLiteError(op string, err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch {
+		case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique:
+			return utility.ErrUniqueCodeValue
+		case sqliteErr.Code == sqlite3.ErrCorrupt || sqliteErr.Code == sqlite3.ErrNotADB:
+			return utility.NewStorageError(utility.ErrCorruptData, op, err)
+		}
+	}
+	return utility.NewStorageError(utility.ErrStorageUnavailable, op, err)
+}
+
+func (st *sqliteProductTx) GetProducts() ([]domain.Product, error) {
+	rows, err := st.q.Query("SELECT " + sqliteProductColumns + " FROM products ORDER BY id")
+	if err != nil {
+		return nil, mapSQLiteError("read products", err)
+	}
+	defer rows.Close()
+
+	products := []domain.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, mapSQLiteError("read products", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapSQLiteError("read products", err)
+	}
+
+	return products, nil
+}
+
+func (st *sqliteProductTx) GetProductById(id int) (domain.Product, error) {
+	row := st.q.QueryRow("SELECT "+sqliteProductColumns+" FROM products WHERE id = ?", id)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, utility.ErrProductNotFound
+	}
+	if err != nil {
+		return domain.Product{}, mapSQLiteError("read product", err)
+	}
+
+	return product, nil
+}
+
+func (st *sqliteProductTx) CreateProduct(reqProduct utility.ProductRequest) (domain.Product, error) {
+	id := st.idGen.Next()
+	_, err := st.q.Exec(
+		"INSERT INTO products ("+sqliteProductColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)",
+		id,
+		reqProduct.Name,
+		reqProduct.Quantity,
+		reqProduct.CodeValue,
+		reqProduct.IsPublished,
+		reqProduct.Expiration,
+		reqProduct.Price.Amount,
+		reqProduct.Price.Currency,
+	)
+	if err != nil {
+		return domain.Product{}, mapSQLiteError("create product", err)
+	}
+
+	return domain.Product{
+		Id:          id,
+		Name:        reqProduct.Name,
+		Quantity:    reqProduct.Quantity,
+		CodeValue:   reqProduct.CodeValue,
+		IsPublished: reqProduct.IsPublished,
+		Expiration:  reqProduct.Expiration,
+		Price:       reqProduct.Price,
+		Version:     1,
+	}, nil
+}
+
+func (st *sqliteProductTx) UpdateProduct(id int, reqProduct utility.ProductRequest) (domain.Product, error) {
+	product := domain.Product{
+		Id:          id,
+		Name:        reqProduct.Name,
+		Quantity:    reqProduct.Quantity,
+		CodeValue:   reqProduct.CodeValue,
+		IsPublished: reqProduct.IsPublished,
+		Expiration:  reqProduct.Expiration,
+		Price:       reqProduct.Price,
+	}
+
+	return st.updateRow(product)
+}
+
+func (st *sqliteProductTx) DeleteProduct(id int) error {
+	result, err := st.q.Exec("DELETE FROM products WHERE id = ?", id)
+	if err != nil {
+		return mapSQLiteError("delete product", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return mapSQLiteError("delete product", err)
+	}
+	if affected == 0 {
+		return utility.ErrProductNotFound
+	}
+
+	return nil
+}
+
+func (st *sqliteProductTx) UpdatePatchProduct(id int, reqProduct utility.ProductPatchRequest) (domain.Product, error) {
+	product, err := st.GetProductById(id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if reqProduct.Name != nil {
+		product.Name = *reqProduct.Name
+	}
+
+	if reqProduct.Quantity != nil {
+		product.Quantity = *reqProduct.Quantity
+	}
+
+	if reqProduct.CodeValue != nil {
+		product.CodeValue = *reqProduct.CodeValue
+	}
+
+	if reqProduct.IsPublished != nil {
+		product.IsPublished = *reqProduct.IsPublished
+	}
+
+	if reqProduct.Expiration != nil {
+		product.Expiration = *reqProduct.Expiration
+	}
+
+	if reqProduct.Price != nil {
+		product.Price = *reqProduct.Price
+	}
+
+	return st.updateRow(product)
+}
+
+// updateRow writes product over the row of its id, incrementing the version, and returns it with the new version.
+func (st *sqliteProductTx) updateRow(product domain.Product) (domain.Product, error) {
+	row := st.q.QueryRow(
+		"UPDATE products SET name = ?, quantity = ?, code_value = ?, is_published = ?, expiration = ?, price = ?, currency = ?, version = version + 1 WHERE id = ? RETURNING version",
+		product.Name,
+		product.Quantity,
+		product.CodeValue,
+		product.IsPublished,
+		product.Expiration,
+		product.Price.Amount,
+		product.Price.Currency,
+		product.Id,
+	)
+
+	err := row.Scan(&product.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, utility.ErrProductNotFound
+	}
+	if err != nil {
+		return domain.Product{}, mapSQLiteError("update product", err)
+	}
+
+	return product, nil
+}
+
+// IterateProducts reads the products by batches of ids, so no statement stays open while fn runs.
+func (rp *repositoryProductSQLite) IterateProducts(fn func(product domain.Product) error) error {
+	lastId := 0
+	for {
+		batch, err := rp.productsAfter(lastId, iterateBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, product := range batch {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < iterateBatchSize {
+			return nil
+		}
+		lastId = batch[len(batch)-1].Id
+	}
+}
+
+// productsAfter returns at most limit products with an id greater than id, in id order.
+func (rp *repositoryProductSQLite) productsAfter(id int, limit int) ([]domain.Product, error) {
+	rows, err := rp.db.Query("SELECT "+sqliteProductColumns+" FROM products WHERE id > ? ORDER BY id LIMIT ?", id, limit)
+	if err != nil {
+		return nil, mapSQLiteError("read products", err)
+	}
+	defer rows.Close()
+
+	products := make([]domain.Product, 0, limit)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, mapSQLiteError("read products", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, mapSQLiteError("read products", err)
+	}
+
+	return products, nil
+}
+
+func (rp *repositoryProductSQLite) WithTx(fn func(tx RepositoryProductTx) error) error {
+	tx, err := rp.db.Begin()
+	if err != nil {
+		return mapSQLiteError("begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqliteProductTx{q: tx, idGen: rp.idGen}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return mapSQLiteError("commit transaction", err)
+	}
+
+	return nil
+}
+
+// UpdatePatchProduct reads and rewrites the row inside a transaction so concurrent patches are not lost.
+func (rp *repositoryProductSQLite) UpdatePatchProduct(id int, reqProduct utility.ProductPatchRequest) (product domain.Product, err error) {
+	err = rp.WithTx(func(tx RepositoryProductTx) error {
+		product, err = tx.UpdatePatchProduct(id, reqProduct)
+		return err
+	})
+	return product, err
+}
+
+// seed copies every product of stHandler into the products table, keeping their ids.
+func (rp *repositoryProductSQLite) seed(stHandler StorageProduct) error {
+	products, err := stHandler.GetProducts()
+	if err != nil {
+		return err
+	}
+
+	tx, err := rp.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO products (" + sqliteProductColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, product := range products {
+		if _, err := stmt.Exec(
+			product.Id,
+			product.Name,
+			product.Quantity,
+			product.CodeValue,
+			product.IsPublished,
+			product.Expiration,
+			product.Price.Amount,
+			product.Price.Currency,
+			product.Version,
+		); err != nil {
+			return mapSQLiteError("seed products", err)
+		}
+	}
+
+	// Carry over the sequence of the storage so ids of products deleted there are not reused.
+	lastId, err := stHandler.GetLastId()
+	if err != nil {
+		return err
+	}
+	var seq int
+	err = tx.QueryRow("SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'products'), 0)").Scan(&seq)
+	if err != nil {
+		return err
+	}
+	if err := setProductSequence(tx, max(seq, lastId)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrateVersionColumn adds the version column to products tables created before versions were introduced.
+// Their products get version 0 until their next write, as products read from older JSON storage do.
+func migrateVersionColumn(db *sql.DB) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('products') WHERE name = 'version'").Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	_, err = db.Exec("ALTER TABLE products ADD COLUMN version INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+// migratePriceColumns converts products tables created before prices had a currency, which stored prices as REAL
+// major units, to INTEGER minor units of domain.DefaultCurrency. SQLite cannot change the type of a column, so the
+// table is rebuilt, keeping its ids and AUTOINCREMENT sequence.
+func migratePriceColumns(db *sql.DB) error {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('products') WHERE name = 'currency'").Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastId int
+	err = tx.QueryRow("SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'products'), 0)").Scan(&lastId)
+	if err != nil {
+		return err
+	}
+
+	// The index is dropped first: index names are global, so the new table could not create its own.
+	for _, statement := range []string{
+		"DROP INDEX IF EXISTS idx_products_code_value",
+		"ALTER TABLE products RENAME TO products_real_price",
+		sqliteSchema,
+	} {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	scale := math.Pow10(domain.CurrencyExponent(domain.DefaultCurrency))
+	_, err = tx.Exec(
+		"INSERT INTO products ("+sqliteProductColumns+") "+
+			"SELECT id, name, quantity, code_value, is_published, expiration, CAST(ROUND(price * ?) AS INTEGER), ?, version "+
+			"FROM products_real_price",
+		scale,
+		domain.DefaultCurrency,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DROP TABLE products_real_price"); err != nil {
+		return err
+	}
+
+	if lastId > 0 {
+		if err := setProductSequence(tx, lastId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setProductSequence makes lastId the last id allocated to products. sqlite_sequence has no row for products
+// until one is inserted, so the row is replaced rather than updated.
+func setProductSequence(tx *sql.Tx, lastId int) error {
+	if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = 'products'"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO sqlite_sequence (name, seq) VALUES ('products', ?)", lastId); err != nil {
+		return err
+	}
+	return nil
+}
+
+// NewRepositoryProductSQLite creates the products schema on db if needed.
+// db should be opened with _txlock=immediate so concurrent transactions queue on the write lock instead of failing.
+// When stHandler is not nil and the products table is empty, it is seeded with the products of stHandler.
+func NewRepositoryProductSQLite(db *sql.DB, stHandler StorageProduct) (*repositoryProductSQLite, error) {
+	if db == nil {
+		return nil, errors.New("db cannot be nil")
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+	if err := migrateVersionColumn(db); err != nil {
+		return nil, err
+	}
+	if err := migratePriceColumns(db); err != nil {
+		return nil, err
+	}
+
+	rp := &repositoryProductSQLite{
+		sqliteProductTx: sqliteProductTx{q: db},
+		db:              db,
+	}
+
+	if stHandler != nil {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM products").Scan(&count); err != nil {
+			return nil, err
+		}
+
+		if count == 0 {
+			if err := rp.seed(stHandler); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// AUTOINCREMENT keeps the highest id ever inserted in sqlite_sequence, even after deletes.
+	var lastId int
+	err := db.QueryRow("SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'products'), 0)").Scan(&lastId)
+	if err != nil {
+		return nil, err
+	}
+	rp.idGen = NewSequentialIdGenerator(lastId)
+
+	return rp, nil
+}
@@ -0,0 +1,152 @@
+package repository_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:"+t.TempDir()+"/products.db?_busy_timeout=5000&_txlock=immediate")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteCreateProduct(t *testing.T) {
+	t.Run("sucess should insert a product and read it back", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+		require.NoError(t, err)
+
+		reqProduct := utility.ProductRequest{Name: "test", Quantity: 23, CodeValue: "testcode", IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")}
+
+		// Act
+		created, err := rp.CreateProduct(reqProduct)
+		require.NoError(t, err)
+		product, err := rp.GetProductById(created.Id)
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 1, created.Id)
+		require.Equal(t, created, product)
+	})
+
+	t.Run("should return an error when the code value already exists", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+		require.NoError(t, err)
+
+		reqProduct := utility.ProductRequest{Name: "test", Quantity: 23, CodeValue: "testcode", IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")}
+		_, err = rp.CreateProduct(reqProduct)
+		require.NoError(t, err)
+
+		// Act
+		_, err = rp.CreateProduct(reqProduct)
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrUniqueCodeValue)
+	})
+}
+
+func TestSQLiteUpdatePatchProduct(t *testing.T) {
+	t.Run("sucess should update only the given fields", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+		require.NoError(t, err)
+
+		created, err := rp.CreateProduct(utility.ProductRequest{Name: "test", Quantity: 23, CodeValue: "testcode", IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")})
+		require.NoError(t, err)
+
+		name := "patched"
+
+		// Act
+		product, err := rp.UpdatePatchProduct(created.Id, utility.ProductPatchRequest{Name: &name})
+
+		// Assert
+		require.NoError(t, err)
+		created.Name = name
+		created.Version = 2
+		require.Equal(t, created, product)
+	})
+}
+
+func TestSQLiteDeleteProduct(t *testing.T) {
+	t.Run("should return an error when the product does not exist", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+		require.NoError(t, err)
+
+		// Act
+		err = rp.DeleteProduct(1)
+
+		// Assert
+		require.ErrorIs(t, err, utility.ErrProductNotFound)
+	})
+}
+
+func TestSQLiteMigratePriceColumns(t *testing.T) {
+	t.Run("sucess should convert real prices to minor units of the default currency", func(t *testing.T) {
+		// Arrange
+		db := newTestDB(t)
+		_, err := db.Exec(`
+CREATE TABLE products (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	name         TEXT    NOT NULL,
+	quantity     INTEGER NOT NULL,
+	code_value   TEXT    NOT NULL,
+	is_published BOOLEAN NOT NULL DEFAULT 0,
+	expiration   TEXT    NOT NULL,
+	price        REAL    NOT NULL
+);
+CREATE UNIQUE INDEX idx_products_code_value ON products (code_value);
+INSERT INTO products (id, name, quantity, code_value, is_published, expiration, price) VALUES
+	(1, 'first', 10, 'code-1', 1, '15/12/2021', 898.54),
+	(3, 'second', 5, 'code-3', 0, '01/01/2022', 0.1);
+DELETE FROM products WHERE id = 3;
+INSERT INTO products (id, name, quantity, code_value, is_published, expiration, price) VALUES
+	(2, 'second', 5, 'code-2', 0, '01/01/2022', 0.1);
+`)
+		require.NoError(t, err)
+
+		// Act
+		rp, err := repository.NewRepositoryProductSQLite(db, nil)
+		require.NoError(t, err)
+		products, err := rp.GetProducts()
+		require.NoError(t, err)
+		created, err := rp.CreateProduct(utility.ProductRequest{Name: "third", Quantity: 1, CodeValue: "code-4", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(1, "")})
+		require.NoError(t, err)
+		_, duplicateErr := rp.CreateProduct(utility.ProductRequest{Name: "copy", Quantity: 1, CodeValue: "code-1", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(1, "")})
+
+		// Assert
+		require.Len(t, products, 2)
+		require.Equal(t, domain.Money{Amount: 89854, Currency: domain.DefaultCurrency}, products[0].Price)
+		require.Equal(t, domain.Money{Amount: 10, Currency: domain.DefaultCurrency}, products[1].Price)
+		require.Equal(t, 0, products[0].Version)
+		require.Equal(t, 4, created.Id)
+		require.ErrorIs(t, duplicateErr, utility.ErrUniqueCodeValue)
+	})
+}
+
+func TestSQLiteSeed(t *testing.T) {
+	t.Run("should carry over the sequence of an empty storage", func(t *testing.T) {
+		// Arrange
+		filename := t.TempDir() + "/products.json"
+		require.NoError(t, os.WriteFile(filename, []byte("[]"), 0644))
+		require.NoError(t, os.WriteFile(filename+".seq", []byte("5\n"), 0644))
+
+		// Act
+		rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), repository.NewStorageProduct(filename))
+		require.NoError(t, err)
+		created, err := rp.CreateProduct(utility.ProductRequest{Name: "test", Quantity: 1, CodeValue: "testcode", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(1, "")})
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 6, created.Id)
+	})
+}
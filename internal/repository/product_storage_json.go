@@ -1,29 +1,56 @@
 package repository
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
 )
 
 type StorageProduct interface {
+	// GetProducts returns the persisted products, with every logged change applied.
 	GetProducts() ([]domain.Product, error)
+	// WriteProducts replaces the snapshot with products and discards the log.
 	WriteProducts(products map[int]domain.Product) error
+	// GetLastId returns the last product id persisted with WriteLastId or AppendChanges, or 0 when none was.
+	GetLastId() (int, error)
+	WriteLastId(id int) error
+	// AppendChanges durably records a committed change set in the write-ahead log.
+	AppendChanges(put []domain.Product, deleted []int, lastId int) error
+	// NeedsCompaction reports whether the log has grown enough to be folded into the snapshot with WriteProducts.
+	NeedsCompaction() bool
 }
 
+// logRecord is one line of the write-ahead log, holding every change of a single transaction.
+type logRecord struct {
+	LastId int              `json:"last_id"`
+	Put    []domain.Product `json:"put,omitempty"`
+	Delete []int            `json:"delete,omitempty"`
+}
+
+// defaultCompactEvery is the number of log records after which the log is folded into the snapshot.
+const defaultCompactEvery = 1000
+
 type storageProduct struct {
 	filename string
+	// compactEvery is the number of log records after which NeedsCompaction reports true.
+	compactEvery int
+	// logRecords is the number of records currently in the log.
+	logRecords int
 }
 
 func (sp *storageProduct) GetProducts() ([]domain.Product, error) {
 	f, err := os.Open(sp.filename)
 	if err != nil {
-		log.Fatalf("unable to read file: %v", err)
-		return nil, err
+		return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "read products", err)
 	}
 	defer f.Close()
 
@@ -33,8 +60,7 @@ func (sp *storageProduct) GetProducts() ([]domain.Product, error) {
 
 	// Read the open bracket
 	if _, err := decoder.Token(); err != nil {
-		log.Fatal(err)
-		return nil, err
+		return nil, utility.NewStorageError(utility.ErrCorruptData, "read products", err)
 	}
 
 	// While the array contains values
@@ -44,19 +70,52 @@ func (sp *storageProduct) GetProducts() ([]domain.Product, error) {
 		if err := decoder.Decode(&product); err == io.EOF {
 			break
 		} else if err != nil {
-			log.Fatal(err)
-			return nil, err
+			return nil, utility.NewStorageError(utility.ErrCorruptData, "read products", err)
 		}
 
-		fmt.Println("Reading:", product)
 		products = append(products, product)
 	}
 
 	// Read the closing bracket
 	if _, err := decoder.Token(); err != nil {
-		log.Fatal(err)
+		return nil, utility.NewStorageError(utility.ErrCorruptData, "read products", err)
+	}
+
+	records, err := sp.readLog()
+	if err != nil {
 		return nil, err
 	}
+	if len(records) == 0 {
+		return products, nil
+	}
+
+	// Replay the log on top of the snapshot. Records are idempotent, so replaying
+	// records already folded into the snapshot by an interrupted compaction is harmless.
+	productsMap := make(map[int]domain.Product, len(products))
+	order := make([]int, 0, len(products))
+	for _, product := range products {
+		productsMap[product.Id] = product
+		order = append(order, product.Id)
+	}
+	for _, record := range records {
+		for _, product := range record.Put {
+			if _, ok := productsMap[product.Id]; !ok {
+				order = append(order, product.Id)
+			}
+			productsMap[product.Id] = product
+		}
+		for _, id := range record.Delete {
+			delete(productsMap, id)
+		}
+	}
+
+	products = make([]domain.Product, 0, len(productsMap))
+	for _, id := range order {
+		if product, ok := productsMap[id]; ok {
+			products = append(products, product)
+			delete(productsMap, id)
+		}
+	}
 
 	return products, nil
 }
@@ -68,26 +127,194 @@ func (sp *storageProduct) WriteProducts(productsMap map[int]domain.Product) erro
 		products = append(products, product)
 	}
 
-	file, err := os.Create(sp.filename)
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(products); err != nil {
+		return utility.NewStorageError(utility.ErrCorruptData, "encode products", err)
+	}
+
+	if err := writeFileAtomic(sp.filename, buf.Bytes()); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "write products", err)
+	}
+
+	// The snapshot now holds every logged change.
+	if err := os.Truncate(sp.logFilename(), 0); err != nil && !os.IsNotExist(err) {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "truncate log", err)
+	}
+	sp.logRecords = 0
+
+	return nil
+}
+
+// sequenceFilename is the file, next to the products file, holding the last allocated id.
+func (sp *storageProduct) sequenceFilename() string {
+	return sp.filename + ".seq"
+}
+
+// logFilename is the write-ahead log file, next to the products file.
+func (sp *storageProduct) logFilename() string {
+	return sp.filename + ".wal"
+}
+
+func (sp *storageProduct) GetLastId() (int, error) {
+	var lastId int
+
+	data, err := os.ReadFile(sp.sequenceFilename())
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return 0, utility.NewStorageError(utility.ErrStorageUnavailable, "read sequence", err)
+	default:
+		if lastId, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return 0, utility.NewStorageError(utility.ErrCorruptData, "read sequence", err)
+		}
+	}
+
+	records, err := sp.readLog()
 	if err != nil {
-		log.Fatal(err)
-		return err
+		return 0, err
+	}
+	for _, record := range records {
+		lastId = max(lastId, record.LastId)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
+	return lastId, nil
+}
 
-	if err := encoder.Encode(products); err != nil {
-		log.Fatal(err)
+func (sp *storageProduct) WriteLastId(id int) error {
+	if err := writeFileAtomic(sp.sequenceFilename(), []byte(strconv.Itoa(id)+"\n")); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "write sequence", err)
+	}
+	return nil
+}
+
+func (sp *storageProduct) AppendChanges(put []domain.Product, deleted []int, lastId int) error {
+	if err := appendLogRecord(sp.logFilename(), logRecord{LastId: lastId, Put: put, Delete: deleted}); err != nil {
 		return err
 	}
 
-	//fmt.Println("JSON data written to file successfully.")
+	sp.logRecords++
 	return nil
 }
 
+func (sp *storageProduct) NeedsCompaction() bool {
+	return sp.logRecords >= sp.compactEvery
+}
+
+func (sp *storageProduct) readLog() ([]logRecord, error) {
+	records, err := readLogRecords[logRecord](sp.logFilename())
+	if err != nil {
+		return nil, err
+	}
+
+	sp.logRecords = len(records)
+	return records, nil
+}
+
+// appendLogRecord durably appends record, as a line of JSON, to the write-ahead log filename.
+func appendLogRecord(filename string, record any) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return utility.NewStorageError(utility.ErrCorruptData, "encode log record", err)
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "append log", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "append log", err)
+	}
+	if err := f.Sync(); err != nil {
+		return utility.NewStorageError(utility.ErrStorageUnavailable, "append log", err)
+	}
+
+	return nil
+}
+
+// readLogRecords decodes every record of the write-ahead log filename. A torn last record, left by a crash
+// in the middle of an append, is cut from the file; corruption anywhere else is an error.
+func readLogRecords[T any](filename string) ([]T, error) {
+	f, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "read log", err)
+	}
+	defer f.Close()
+
+	var records []T
+	var offset int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "read log", err)
+		}
+
+		var record T
+		if jsonErr := json.Unmarshal(line, &record); jsonErr != nil || line[len(line)-1] != '\n' {
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				return nil, utility.NewStorageError(utility.ErrCorruptData, "read log", fmt.Errorf("bad record at offset %d", offset))
+			}
+			if err := f.Truncate(offset); err != nil {
+				return nil, utility.NewStorageError(utility.ErrStorageUnavailable, "truncate log", err)
+			}
+			break
+		}
+
+		records = append(records, record)
+		offset += int64(len(line))
+	}
+
+	return records, nil
+}
+
+// writeFileAtomic replaces filename with data so that readers, and the file after a crash,
+// only ever see either the old or the new content.
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return err
+	}
+
+	// Persist the rename itself.
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
 func NewStorageProduct(filename string) *storageProduct {
 	return &storageProduct{
-		filename: filename,
+		filename:     filename,
+		compactEvery: defaultCompactEvery,
 	}
 }
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"os"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) *storageProduct {
+	filename := t.TempDir() + "/products.json"
+	require.NoError(t, os.WriteFile(filename, []byte(`[{"id":1,"name":"Product 1","quantity":10,"code_value":"12345","is_published":true,"expiration":"2023-01-01","price":100}]`), 0644))
+	return NewStorageProduct(filename)
+}
+
+func TestStorageProductLog(t *testing.T) {
+	t.Run("sucess should replay logged changes on load", func(t *testing.T) {
+		// Arrange
+		storage := newTestStorage(t)
+		rp, err := NewRepositoryProduct(nil, storage)
+		require.NoError(t, err)
+
+		_, err = rp.CreateProduct(utility.ProductRequest{Name: "test", Quantity: 23, CodeValue: "testcode", IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")})
+		require.NoError(t, err)
+		require.NoError(t, rp.DeleteProduct(1))
+
+		// Act
+		products, err := NewStorageProduct(storage.filename).GetProducts()
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, []domain.Product{
+			{Id: 2, Name: "test", Quantity: 23, CodeValue: "testcode", IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, ""), Version: 1},
+		}, products)
+	})
+
+	t.Run("should ignore a torn last record", func(t *testing.T) {
+		// Arrange
+		storage := newTestStorage(t)
+		require.NoError(t, storage.AppendChanges(nil, []int{1}, 1))
+
+		f, err := os.OpenFile(storage.logFilename(), os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"last_id":2,"put":[{"id":2,`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		// Act
+		products, err := storage.GetProducts()
+
+		// Assert
+		require.NoError(t, err)
+		require.Empty(t, products)
+		lastId, err := storage.GetLastId()
+		require.NoError(t, err)
+		require.Equal(t, 1, lastId)
+	})
+
+	t.Run("should fold the log into the snapshot once it is large enough", func(t *testing.T) {
+		// Arrange
+		storage := newTestStorage(t)
+		storage.compactEvery = 2
+		rp, err := NewRepositoryProduct(nil, storage)
+		require.NoError(t, err)
+
+		// Act
+		_, err = rp.CreateProduct(utility.ProductRequest{Name: "a", Quantity: 1, CodeValue: "a", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(1, "")})
+		require.NoError(t, err)
+		_, err = rp.CreateProduct(utility.ProductRequest{Name: "b", Quantity: 1, CodeValue: "b", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(1, "")})
+		require.NoError(t, err)
+
+		// Assert
+		info, err := os.Stat(storage.logFilename())
+		require.NoError(t, err)
+		require.Zero(t, info.Size())
+
+		products, err := NewStorageProduct(storage.filename).GetProducts()
+		require.NoError(t, err)
+		require.Len(t, products, 3)
+
+		lastId, err := storage.GetLastId()
+		require.NoError(t, err)
+		require.Equal(t, 3, lastId)
+	})
+}
@@ -0,0 +1,324 @@
+package repository_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+const concurrentWriters = 50
+
+func newProductRequest(codeValue string) utility.ProductRequest {
+	return utility.ProductRequest{Name: "test", Quantity: 23, CodeValue: codeValue, IsPublished: true, Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")}
+}
+
+// createConcurrently calls CreateProduct from concurrentWriters goroutines and returns the created products and errors.
+func createConcurrently(rp repository.RepositoryProduct, codeValue func(i int) string) ([]domain.Product, []error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		products []domain.Product
+		errs     []error
+	)
+
+	for i := 0; i < concurrentWriters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			product, err := rp.CreateProduct(newProductRequest(codeValue(i)))
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			products = append(products, product)
+		}(i)
+	}
+	wg.Wait()
+
+	return products, errs
+}
+
+func TestConcurrentCreateProduct(t *testing.T) {
+	repositories := map[string]func(t *testing.T) repository.RepositoryProduct{
+		"map": func(t *testing.T) repository.RepositoryProduct {
+			rp, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+			require.NoError(t, err)
+			return rp
+		},
+		"sqlite": func(t *testing.T) repository.RepositoryProduct {
+			rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+			require.NoError(t, err)
+			return rp
+		},
+	}
+
+	for name, newRepository := range repositories {
+		t.Run(name+" sucess should allocate a distinct id to every product", func(t *testing.T) {
+			// Arrange
+			rp := newRepository(t)
+
+			// Act
+			products, errs := createConcurrently(rp, func(i int) string { return fmt.Sprintf("code-%d", i) })
+
+			// Assert
+			require.Empty(t, errs)
+			ids := map[int]bool{}
+			for _, product := range products {
+				ids[product.Id] = true
+			}
+			require.Len(t, ids, concurrentWriters)
+		})
+
+		t.Run(name+" should accept a code value only once", func(t *testing.T) {
+			// Arrange
+			rp := newRepository(t)
+
+			// Act
+			products, errs := createConcurrently(rp, func(i int) string { return "same-code" })
+
+			// Assert
+			require.Len(t, products, 1)
+			require.Len(t, errs, concurrentWriters-1)
+			for _, err := range errs {
+				require.ErrorIs(t, err, utility.ErrUniqueCodeValue)
+			}
+		})
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	t.Run("should discard every change when fn returns an error", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProduct(map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+		}, nil)
+		require.NoError(t, err)
+		errAbort := errors.New("abort")
+
+		// Act
+		err = rp.WithTx(func(tx repository.RepositoryProductTx) error {
+			if _, err := tx.CreateProduct(newProductRequest("new-code")); err != nil {
+				return err
+			}
+			if err := tx.DeleteProduct(1); err != nil {
+				return err
+			}
+			return errAbort
+		})
+
+		// Assert
+		require.ErrorIs(t, err, errAbort)
+		products, err := rp.GetProducts()
+		require.NoError(t, err)
+		require.Equal(t, []domain.Product{
+			{Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+		}, products)
+	})
+}
+
+func TestCreateProductAfterDelete(t *testing.T) {
+	t.Run("should not reuse the id of a deleted product", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProduct(map[int]domain.Product{
+			1: {Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+			2: {Id: 2, Name: "Product 2", Quantity: 20, CodeValue: "67890", IsPublished: false, Expiration: domain.MustParseDate("2023-01-02"), Price: domain.MoneyFromFloat(200.0, "")},
+		}, nil)
+		require.NoError(t, err)
+		require.NoError(t, rp.DeleteProduct(1))
+
+		// Act
+		product, err := rp.CreateProduct(newProductRequest("new-code"))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 3, product.Id)
+	})
+
+	t.Run("sqlite should not reuse the id of a deleted product after a restart", func(t *testing.T) {
+		// Arrange
+		db := newTestDB(t)
+		rp, err := repository.NewRepositoryProductSQLite(db, nil)
+		require.NoError(t, err)
+		created, err := rp.CreateProduct(newProductRequest("code"))
+		require.NoError(t, err)
+		require.NoError(t, rp.DeleteProduct(created.Id))
+
+		// Act
+		rp, err = repository.NewRepositoryProductSQLite(db, nil)
+		require.NoError(t, err)
+		product, err := rp.CreateProduct(newProductRequest("code"))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, created.Id+1, product.Id)
+	})
+}
+
+func TestCreateProductAfterReload(t *testing.T) {
+	t.Run("json storage should persist the id sequence", func(t *testing.T) {
+		// Arrange
+		filename := t.TempDir() + "/products.json"
+		require.NoError(t, os.WriteFile(filename, []byte("[]"), 0644))
+		storage := repository.NewStorageProduct(filename)
+
+		rp, err := repository.NewRepositoryProduct(nil, storage)
+		require.NoError(t, err)
+		created, err := rp.CreateProduct(newProductRequest("code"))
+		require.NoError(t, err)
+		require.NoError(t, rp.DeleteProduct(created.Id))
+
+		// Act
+		rp, err = repository.NewRepositoryProduct(nil, storage)
+		require.NoError(t, err)
+		product, err := rp.CreateProduct(newProductRequest("code"))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, created.Id+1, product.Id)
+	})
+}
+
+// failingStorage is a StorageProduct whose writes always fail.
+type failingStorage struct{}
+
+func (failingStorage) GetProducts() ([]domain.Product, error) {
+	return []domain.Product{
+		{Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+	}, nil
+}
+
+func (failingStorage) WriteProducts(map[int]domain.Product) error {
+	return utility.NewStorageError(utility.ErrStorageUnavailable, "write products", errors.New("disk full"))
+}
+
+func (failingStorage) GetLastId() (int, error) { return 0, nil }
+
+func (failingStorage) WriteLastId(int) error {
+	return utility.NewStorageError(utility.ErrStorageUnavailable, "write sequence", errors.New("disk full"))
+}
+
+func (failingStorage) AppendChanges([]domain.Product, []int, int) error {
+	return utility.NewStorageError(utility.ErrStorageUnavailable, "append log", errors.New("disk full"))
+}
+
+func (failingStorage) NeedsCompaction() bool { return false }
+
+func TestPersistenceFailure(t *testing.T) {
+	t.Run("should return the storage error and keep the previous state", func(t *testing.T) {
+		// Arrange
+		rp, err := repository.NewRepositoryProduct(nil, failingStorage{})
+		require.NoError(t, err)
+		name := "patched"
+
+		// Act
+		_, createErr := rp.CreateProduct(newProductRequest("new-code"))
+		_, patchErr := rp.UpdatePatchProduct(1, utility.ProductPatchRequest{Name: &name})
+		deleteErr := rp.DeleteProduct(1)
+
+		// Assert
+		require.ErrorIs(t, createErr, utility.ErrStorageUnavailable)
+		require.ErrorIs(t, patchErr, utility.ErrStorageUnavailable)
+		require.ErrorIs(t, deleteErr, utility.ErrStorageUnavailable)
+
+		products, err := rp.GetProducts()
+		require.NoError(t, err)
+		require.Equal(t, []domain.Product{
+			{Id: 1, Name: "Product 1", Quantity: 10, CodeValue: "12345", IsPublished: true, Expiration: domain.MustParseDate("2023-01-01"), Price: domain.MoneyFromFloat(100.0, "")},
+		}, products)
+	})
+}
+
+func TestIterateProducts(t *testing.T) {
+	repositories := map[string]func(t *testing.T) repository.RepositoryProduct{
+		"map": func(t *testing.T) repository.RepositoryProduct {
+			rp, err := repository.NewRepositoryProduct(map[int]domain.Product{}, nil)
+			require.NoError(t, err)
+			return rp
+		},
+		"sqlite": func(t *testing.T) repository.RepositoryProduct {
+			rp, err := repository.NewRepositoryProductSQLite(newTestDB(t), nil)
+			require.NoError(t, err)
+			return rp
+		},
+	}
+
+	for name, newRepository := range repositories {
+		// More than two batches, so the iteration moves from batch to batch.
+		const count = 1203
+
+		newFilledRepository := func(t *testing.T) repository.RepositoryProduct {
+			rp := newRepository(t)
+			require.NoError(t, rp.WithTx(func(tx repository.RepositoryProductTx) error {
+				for i := 0; i < count; i++ {
+					if _, err := tx.CreateProduct(newProductRequest(fmt.Sprintf("code-%d", i))); err != nil {
+						return err
+					}
+				}
+				return nil
+			}))
+			return rp
+		}
+
+		t.Run(name+" sucess should visit every product in id order", func(t *testing.T) {
+			// Arrange
+			rp := newFilledRepository(t)
+			require.NoError(t, rp.DeleteProduct(600))
+
+			// Act
+			var ids []int
+			err := rp.IterateProducts(func(product domain.Product) error {
+				ids = append(ids, product.Id)
+				return nil
+			})
+
+			// Assert
+			require.NoError(t, err)
+			require.Len(t, ids, count-1)
+			require.IsIncreasing(t, ids)
+		})
+
+		t.Run(name+" should stop at the first error of fn", func(t *testing.T) {
+			// Arrange
+			rp := newFilledRepository(t)
+			errStop := errors.New("stop")
+
+			// Act
+			visited := 0
+			err := rp.IterateProducts(func(product domain.Product) error {
+				visited++
+				if visited == 10 {
+					return errStop
+				}
+				return nil
+			})
+
+			// Assert
+			require.ErrorIs(t, err, errStop)
+			require.Equal(t, 10, visited)
+		})
+	}
+}
+
+func TestNewRepositoryProduct(t *testing.T) {
+	t.Run("should return an error when the products file cannot be read", func(t *testing.T) {
+		// Arrange
+		filename := t.TempDir() + "/products.json"
+		require.NoError(t, os.WriteFile(filename, []byte("[{"), 0644))
+
+		// Act
+		_, err := repository.NewRepositoryProduct(nil, repository.NewStorageProduct(filename))
+
+		// Assert
+		require.Error(t, err)
+	})
+}
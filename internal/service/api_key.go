@@ -0,0 +1,201 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/validation"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// DefaultAPIKeyId is the id of the key of the configured token, see BootstrapAPIKey.
+const DefaultAPIKeyId = "default"
+
+type ServiceAPIKey interface {
+	GetAPIKeys() ([]domain.APIKey, error)
+	// IssueAPIKey creates a key and returns it with its secret, which is not stored and cannot be read again.
+	IssueAPIKey(req utility.APIKeyRequest) (domain.APIKey, string, error)
+	// RotateAPIKey replaces the secret of a key, which keeps its id, scopes and expiry. The old secret stops working.
+	RotateAPIKey(pathVariable string) (domain.APIKey, string, error)
+	// RevokeAPIKey disables a key for good.
+	RevokeAPIKey(pathVariable string) (domain.APIKey, error)
+	// Authenticate returns the active key of secret. Errors wrap utility.ErrUnauthorized.
+	Authenticate(secret string) (domain.APIKey, error)
+}
+
+type serviceAPIKey struct {
+	keys  repository.RepositoryAPIKey
+	clock Clock
+}
+
+// hashSecret returns the hash of secret kept in place of it. Secrets are random, so a plain SHA-256 is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSecret returns a random secret for the key id. Secrets are "<id>.<random>" so the key of a secret is found
+// without comparing it against every hash.
+func newSecret(id string) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return id + "." + base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+func newAPIKeyId() (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "key_" + hex.EncodeToString(random), nil
+}
+
+func (sk *serviceAPIKey) GetAPIKeys() ([]domain.APIKey, error) {
+	return sk.keys.GetAPIKeys()
+}
+
+func (sk *serviceAPIKey) IssueAPIKey(req utility.APIKeyRequest) (domain.APIKey, string, error) {
+	now := sk.clock()
+	if err := validation.APIKeyRequest(req, now); err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	id, err := newAPIKeyId()
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	secret, err := newSecret(id)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	key := domain.APIKey{
+		Id:        id,
+		Name:      req.Name,
+		Hash:      hashSecret(secret),
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+
+	if err := sk.keys.CreateAPIKey(key); err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (sk *serviceAPIKey) RotateAPIKey(pathVariable string) (domain.APIKey, string, error) {
+	key, err := sk.keys.GetAPIKeyById(pathVariable)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	now := sk.clock()
+	if !key.Active(now) {
+		return domain.APIKey{}, "", fmt.Errorf("%w: a revoked or expired key cannot be rotated", utility.ErrInvalidValues)
+	}
+
+	secret, err := newSecret(key.Id)
+	if err != nil {
+		return domain.APIKey{}, "", err
+	}
+	key.Hash = hashSecret(secret)
+	key.RotatedAt = &now
+
+	if err := sk.keys.UpdateAPIKey(key); err != nil {
+		return domain.APIKey{}, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (sk *serviceAPIKey) RevokeAPIKey(pathVariable string) (domain.APIKey, error) {
+	key, err := sk.keys.GetAPIKeyById(pathVariable)
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	now := sk.clock()
+	key.RevokedAt = &now
+	if err := sk.keys.UpdateAPIKey(key); err != nil {
+		return domain.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+func (sk *serviceAPIKey) Authenticate(secret string) (domain.APIKey, error) {
+	id, _, _ := strings.Cut(secret, ".")
+	key, err := sk.keys.GetAPIKeyById(id)
+	if errors.Is(err, utility.ErrAPIKeyNotFound) {
+		// The configured token has no id prefix, it is the secret of the default key.
+		key, err = sk.keys.GetAPIKeyById(DefaultAPIKeyId)
+	}
+	if errors.Is(err, utility.ErrAPIKeyNotFound) {
+		// Hash anyway, so unknown ids take as long as wrong secrets.
+		hashSecret(secret)
+		return domain.APIKey{}, utility.ErrUnauthorized
+	}
+	if err != nil {
+		return domain.APIKey{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 || !key.Active(sk.clock()) {
+		return domain.APIKey{}, utility.ErrUnauthorized
+	}
+
+	return key, nil
+}
+
+// BootstrapAPIKey stores token as the secret of the default key when the store has no default key yet. Once stored,
+// the default key is managed like any other: rotating or revoking it disables token. Nothing is stored when token is
+// empty.
+func (sk *serviceAPIKey) BootstrapAPIKey(token string) error {
+	if token == "" {
+		return nil
+	}
+
+	_, err := sk.keys.GetAPIKeyById(DefaultAPIKeyId)
+	if !errors.Is(err, utility.ErrAPIKeyNotFound) {
+		return err
+	}
+
+	return sk.keys.CreateAPIKey(domain.APIKey{
+		Id:        DefaultAPIKeyId,
+		Name:      "configured token",
+		Hash:      hashSecret(token),
+		Scopes:    []string{},
+		CreatedAt: sk.clock(),
+	})
+}
+
+func NewServiceAPIKey(keys repository.RepositoryAPIKey) *serviceAPIKey {
+	return &serviceAPIKey{
+		keys:  keys,
+		clock: time.Now,
+	}
+}
+
+func (sk *serviceAPIKey) WithClock(clock Clock) *serviceAPIKey {
+	sk.clock = clock
+	return sk
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type ServiceCart interface {
+	// Every method but ExpireCarts acts on behalf of owner, the subject of the client. The carts of other
+	// clients are reported as not found.
+	CreateCart(owner string, cart utility.CartRequest) (CartView, error)
+	GetCart(owner string, pathVariable string) (CartView, error)
+	AddCartItem(owner string, pathVariable string, item utility.CartItemRequest) (CartView, error)
+	RemoveCartItem(owner string, pathVariable string, productPathVariable string, query url.Values) (CartView, error)
+	DeleteCart(owner string, pathVariable string) error
+	ExpireCarts() (int, error)
+}
+
+// CartIssue explains why an item of a cart is left out of its price.
+type CartIssue struct {
+	ProductId int    `json:"product_id"`
+	Reason    string `json:"reason"`
+}
+
+// CartView is a cart priced with the current products and pricing rules.
+type CartView struct {
+	domain.Cart
+	ExpiresAt time.Time        `json:"expires_at"`
+	Products  []domain.Product `json:"products"`
+	Quote     pricing.Quote    `json:"quote"`
+	// Unavailable lists the items that stopped being available after they were put in the cart.
+	Unavailable []CartIssue `json:"unavailable,omitempty"`
+}
+
+type serviceCart struct {
+	carts        repository.RepositoryCart
+	products     repository.RepositoryProduct
+	pricingRules *pricing.Rules
+	// ttl is how long a cart is kept after its last change.
+	ttl   time.Duration
+	clock Clock
+}
+
+// expired reports whether cart was last changed more than ttl before now.
+func (sc *serviceCart) expired(cart domain.Cart, now time.Time) bool {
+	return cart.UpdatedAt.Before(now.Add(-sc.ttl))
+}
+
+// view prices cart. Items that can no longer be bought are reported in Unavailable instead of failing the view,
+// so they can still be seen and removed.
+func (sc *serviceCart) view(cart domain.Cart) (CartView, error) {
+	today := domain.DateOf(sc.clock())
+
+	view := CartView{
+		Cart:      cart,
+		ExpiresAt: cart.UpdatedAt.Add(sc.ttl),
+		Products:  []domain.Product{},
+	}
+
+	var lines []pricing.Line
+	for _, item := range cart.Items {
+		product, err := sc.products.GetProductById(item.ProductId)
+		if err == nil {
+			err = checkAvailable(product, item.Quantity, today)
+		}
+		switch {
+		case errors.Is(err, utility.ErrProductNotFound), errors.Is(err, utility.ErrProductUnavailable),
+			errors.Is(err, utility.ErrProductExpired), errors.Is(err, utility.ErrInsufficientStock):
+			view.Unavailable = append(view.Unavailable, CartIssue{ProductId: item.ProductId, Reason: err.Error()})
+			continue
+		case err != nil:
+			return CartView{}, err
+		}
+
+		view.Products = append(view.Products, product)
+		lines = append(lines, pricing.Line{Product: product, Quantity: item.Quantity})
+	}
+
+	// A cart below the minimum order is still being filled, so it is priced without that check.
+	quote, err := sc.pricingRules.Price(lines)
+	if err != nil {
+		return CartView{}, err
+	}
+	view.Quote = quote
+
+	return view, nil
+}
+
+// checkItems fails unless every item of items can be bought now, and all of them are priced in the same currency:
+// a cart that mixes currencies could never be priced again.
+func (sc *serviceCart) checkItems(items []domain.CartItem) error {
+	ids := make([]int, 0, len(items))
+	quantities := make(map[int]int, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductId)
+		quantities[item.ProductId] = item.Quantity
+	}
+
+	products, _, err := availableLines(sc.products.GetProductById, ids, quantities, domain.DateOf(sc.clock()))
+	if err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		if currency := products[0].Price.Currency; product.Price.Currency != currency {
+			return fmt.Errorf("%w: %s and %s", utility.ErrCurrencyMismatch, currency, product.Price.Currency)
+		}
+	}
+
+	return nil
+}
+
+// addItem adds quantity units of productId to items, keeping one item per product.
+func addItem(items []domain.CartItem, productId int, quantity int) []domain.CartItem {
+	for i := range items {
+		if items[i].ProductId == productId {
+			items[i].Quantity += quantity
+			return items
+		}
+	}
+	return append(items, domain.CartItem{ProductId: productId, Quantity: quantity})
+}
+
+func parseCartId(pathVariable string) (int, error) {
+	id, err := strconv.Atoi(pathVariable)
+	if err != nil {
+		return 0, utility.ErrInvalidId
+	}
+	return id, nil
+}
+
+// CreateCart creates a cart of owner holding the items of reqCart, which must all be available.
+func (sc *serviceCart) CreateCart(owner string, reqCart utility.CartRequest) (CartView, error) {
+	now := sc.clock()
+	cart := domain.Cart{
+		Owner:     owner,
+		Items:     []domain.CartItem{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for _, item := range reqCart.Items {
+		if item.Quantity <= 0 {
+			return CartView{}, fmt.Errorf("%w: quantity must be positive", utility.ErrInvalidValues)
+		}
+		cart.Items = addItem(cart.Items, item.ProductId, item.Quantity)
+	}
+
+	if err := sc.checkItems(cart.Items); err != nil {
+		return CartView{}, err
+	}
+
+	cart, err := sc.carts.CreateCart(cart)
+	if err != nil {
+		return CartView{}, err
+	}
+
+	return sc.view(cart)
+}
+
+// GetCart returns the priced cart. Abandoned carts are reported as not found.
+func (sc *serviceCart) GetCart(owner string, pathVariable string) (CartView, error) {
+	id, err := parseCartId(pathVariable)
+	if err != nil {
+		return CartView{}, err
+	}
+
+	cart, err := sc.carts.GetCartById(id)
+	if err != nil {
+		return CartView{}, err
+	}
+	if cart.Owner != owner || sc.expired(cart, sc.clock()) {
+		return CartView{}, utility.ErrCartNotFound
+	}
+
+	return sc.view(cart)
+}
+
+// AddCartItem adds the units of reqItem to the cart. The whole cart must still be available after the change.
+func (sc *serviceCart) AddCartItem(owner string, pathVariable string, reqItem utility.CartItemRequest) (CartView, error) {
+	id, err := parseCartId(pathVariable)
+	if err != nil {
+		return CartView{}, err
+	}
+	if reqItem.Quantity <= 0 {
+		return CartView{}, fmt.Errorf("%w: quantity must be positive", utility.ErrInvalidValues)
+	}
+
+	now := sc.clock()
+	cart, err := sc.carts.UpdateCart(id, func(cart *domain.Cart) error {
+		if cart.Owner != owner || sc.expired(*cart, now) {
+			return utility.ErrCartNotFound
+		}
+
+		cart.Items = addItem(cart.Items, reqItem.ProductId, reqItem.Quantity)
+		if err := sc.checkItems(cart.Items); err != nil {
+			return err
+		}
+		cart.UpdatedAt = now
+
+		return nil
+	})
+	if err != nil {
+		return CartView{}, err
+	}
+
+	return sc.view(cart)
+}
+
+// RemoveCartItem removes the number of units given by the quantity parameter of a product from the cart,
+// or the whole item when quantity is missing or covers every unit.
+func (sc *serviceCart) RemoveCartItem(owner string, pathVariable string, productPathVariable string, query url.Values) (CartView, error) {
+	id, err := parseCartId(pathVariable)
+	if err != nil {
+		return CartView{}, err
+	}
+	productId, err := strconv.Atoi(productPathVariable)
+	if err != nil {
+		return CartView{}, utility.ErrInvalidId
+	}
+
+	quantity := 0
+	if value := query.Get("quantity"); value != "" {
+		if quantity, err = strconv.Atoi(value); err != nil || quantity <= 0 {
+			return CartView{}, fmt.Errorf("%w: quantity must be a positive integer", utility.ErrInvalidQuery)
+		}
+	}
+
+	now := sc.clock()
+	cart, err := sc.carts.UpdateCart(id, func(cart *domain.Cart) error {
+		if cart.Owner != owner || sc.expired(*cart, now) {
+			return utility.ErrCartNotFound
+		}
+
+		for i, item := range cart.Items {
+			if item.ProductId != productId {
+				continue
+			}
+
+			if quantity == 0 || quantity >= item.Quantity {
+				cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			} else {
+				cart.Items[i].Quantity -= quantity
+			}
+			cart.UpdatedAt = now
+
+			return nil
+		}
+
+		return utility.ErrProductNotFound
+	})
+	if err != nil {
+		return CartView{}, err
+	}
+
+	return sc.view(cart)
+}
+
+func (sc *serviceCart) DeleteCart(owner string, pathVariable string) error {
+	id, err := parseCartId(pathVariable)
+	if err != nil {
+		return err
+	}
+
+	// The owner of a cart never changes, so it can be checked before deleting.
+	cart, err := sc.carts.GetCartById(id)
+	if err != nil {
+		return err
+	}
+	if cart.Owner != owner {
+		return utility.ErrCartNotFound
+	}
+
+	return sc.carts.DeleteCart(id)
+}
+
+// ExpireCarts deletes the abandoned carts and returns how many it deleted.
+func (sc *serviceCart) ExpireCarts() (int, error) {
+	return sc.carts.DeleteCartsUpdatedBefore(sc.clock().Add(-sc.ttl))
+}
+
+// NewServiceCart creates the cart service. Carts left unchanged for ttl are abandoned.
+// A nil pricingRules uses pricing.DefaultRules.
+func NewServiceCart(carts repository.RepositoryCart, products repository.RepositoryProduct, pricingRules *pricing.Rules, ttl time.Duration) *serviceCart {
+	if pricingRules == nil {
+		pricingRules = pricing.DefaultRules()
+	}
+
+	return &serviceCart{
+		carts:        carts,
+		products:     products,
+		pricingRules: pricingRules,
+		ttl:          ttl,
+		clock:        time.Now,
+	}
+}
+
+// WithClock makes the service use clock to date changes and to decide which carts are abandoned.
+func (sc *serviceCart) WithClock(clock Clock) *serviceCart {
+	sc.clock = clock
+	return sc
+}
+
+// CartExpiryScheduler periodically deletes the abandoned carts of a service.
+type CartExpiryScheduler struct {
+	service  ServiceCart
+	interval time.Duration
+}
+
+// NewCartExpiryScheduler creates a scheduler that deletes the abandoned carts of service every interval.
+func NewCartExpiryScheduler(service ServiceCart, interval time.Duration) *CartExpiryScheduler {
+	return &CartExpiryScheduler{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run deletes the abandoned carts every interval, until ctx is done.
+func (cs *CartExpiryScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(cs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := cs.service.ExpireCarts()
+		if err != nil {
+			log.Printf("cart expiry scheduler: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("cart expiry scheduler: deleted %d abandoned carts", deleted)
+		}
+	}
+}
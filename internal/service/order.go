@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+type ServiceOrder interface {
+	CreateOrder(order utility.OrderRequest) (domain.Order, error)
+	GetOrderById(pathVariable string) (domain.Order, error)
+	CancelOrder(pathVariable string) (domain.Order, error)
+}
+
+type serviceOrder struct {
+	products     repository.RepositoryProduct
+	orders       repository.RepositoryOrder
+	pricingRules *pricing.Rules
+	clock        Clock
+}
+
+// CreateOrder prices the products listed in reqOrder and takes them out of stock in a single product transaction,
+// then stores the order. Stock is only taken when every product is published, not expired and has enough units.
+//
+// The order is stored after the stock is committed. When storing it fails the stock is given back,
+// so a crash in between can only leave units out of stock, never sell them twice.
+func (so *serviceOrder) CreateOrder(reqOrder utility.OrderRequest) (domain.Order, error) {
+	if len(reqOrder.List) == 0 {
+		return domain.Order{}, fmt.Errorf("%w: list must hold at least one product id", utility.ErrInvalidValues)
+	}
+
+	quantities := utility.CountValues(reqOrder.List)
+	ids := make([]int, 0, len(quantities))
+	for id := range quantities {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	now := so.clock()
+	today := domain.DateOf(now)
+
+	var quote pricing.Quote
+	err := so.products.WithTx(func(tx repository.RepositoryProductTx) error {
+		_, lines, err := availableLines(tx.GetProductById, ids, quantities, today)
+		if err != nil {
+			return err
+		}
+
+		if quote, err = so.pricingRules.Evaluate(lines); err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			quantity := line.Product.Quantity - line.Quantity
+			if _, err := tx.UpdatePatchProduct(line.Product.Id, utility.ProductPatchRequest{Quantity: &quantity}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	order := domain.Order{
+		Status:    domain.OrderStatusPlaced,
+		Lines:     make([]domain.OrderLine, 0, len(quote.Lines)),
+		Total:     quote.Total,
+		CreatedAt: now,
+	}
+	for _, line := range quote.Lines {
+		order.Lines = append(order.Lines, domain.OrderLine{
+			ProductId: line.ProductId,
+			Quantity:  line.Quantity,
+			UnitPrice: line.UnitPrice,
+			Total:     line.Total,
+		})
+	}
+
+	created, err := so.orders.CreateOrder(order)
+	if err != nil {
+		if restockErr := so.restock(order.Lines); restockErr != nil {
+			log.Printf("unable to restock the products of a failed order: %v", restockErr)
+		}
+		return domain.Order{}, err
+	}
+
+	return created, nil
+}
+
+func (so *serviceOrder) GetOrderById(pathVariable string) (domain.Order, error) {
+	id, err := strconv.Atoi(pathVariable)
+	if err != nil {
+		return domain.Order{}, utility.ErrInvalidId
+	}
+
+	return so.orders.GetOrderById(id)
+}
+
+// CancelOrder cancels a placed order and puts its products back in stock. Products deleted since
+// the order was placed are not restocked.
+func (so *serviceOrder) CancelOrder(pathVariable string) (domain.Order, error) {
+	id, err := strconv.Atoi(pathVariable)
+	if err != nil {
+		return domain.Order{}, utility.ErrInvalidId
+	}
+
+	previous, err := so.orders.GetOrderById(id)
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	order, err := so.orders.CancelOrder(id, so.clock())
+	if err != nil {
+		return domain.Order{}, err
+	}
+
+	if err := so.restock(order.Lines); err != nil {
+		if revertErr := so.orders.UpdateOrder(previous); revertErr != nil {
+			log.Printf("unable to revert the cancellation of order %d: %v", id, revertErr)
+		}
+		return domain.Order{}, err
+	}
+
+	return order, nil
+}
+
+// restock puts the units of lines back in stock in a single product transaction.
+func (so *serviceOrder) restock(lines []domain.OrderLine) error {
+	return so.products.WithTx(func(tx repository.RepositoryProductTx) error {
+		for _, line := range lines {
+			product, err := tx.GetProductById(line.ProductId)
+			if errors.Is(err, utility.ErrProductNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			quantity := product.Quantity + line.Quantity
+			if _, err := tx.UpdatePatchProduct(product.Id, utility.ProductPatchRequest{Quantity: &quantity}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// NewServiceOrder creates the order service. A nil pricingRules uses pricing.DefaultRules.
+func NewServiceOrder(products repository.RepositoryProduct, orders repository.RepositoryOrder, pricingRules *pricing.Rules) *serviceOrder {
+	if pricingRules == nil {
+		pricingRules = pricing.DefaultRules()
+	}
+
+	return &serviceOrder{
+		products:     products,
+		orders:       orders,
+		pricingRules: pricingRules,
+		clock:        time.Now,
+	}
+}
+
+// WithClock makes the service use clock to date orders and to decide which products are expired.
+func (so *serviceOrder) WithClock(clock Clock) *serviceOrder {
+	so.clock = clock
+	return so
+}
@@ -1,31 +1,65 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/patch"
+	"github.com/MDavidCV/go-web-module/internal/pricing"
 	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/validation"
 	"github.com/MDavidCV/go-web-module/utility"
 )
 
 type ServiceProduct interface {
-	GetProducts() ([]domain.Product, error)
+	GetProducts(query url.Values) (ProductPage, error)
+	StreamProducts(query url.Values) (ProductStream, error)
 	GetProductById(pathVariable string) (domain.Product, error)
-	SearchProduct(query string) ([]domain.Product, error)
+	SearchProduct(query url.Values) ([]domain.Product, error)
 	CreateProduct(product utility.ProductRequest) (domain.Product, error)
-	UpdateProduct(pathVariable string, product utility.ProductRequest) (domain.Product, error)
-	DeleteProduct(pathVariable string) error
-	UpdatePatchProduct(pathVariable string, product utility.ProductPatchRequest) (domain.Product, error)
-	GetConsumerPrice(query string) ([]domain.Product, float64, error)
+	// The writes of existing products take the value of the If-Match header of the request, empty when
+	// there is none, and fail with utility.ErrPreconditionFailed when it does not match the stored product.
+	UpdateProduct(pathVariable string, product utility.ProductRequest, ifMatch string) (domain.Product, error)
+	DeleteProduct(pathVariable string, ifMatch string) error
+	UpdatePatchProduct(pathVariable string, product utility.ProductPatchRequest, ifMatch string) (domain.Product, error)
+	ApplyProductPatch(pathVariable string, p patch.Patch, ifMatch string) (domain.Product, error)
+	CreateProducts(query url.Values, products []utility.ProductRequest) (BulkResult, error)
+	PatchProducts(query url.Values, items []utility.ProductBulkPatchItem) (BulkResult, error)
+	ExportProducts(query url.Values) (ProductStream, error)
+	ImportProducts(query url.Values, mediaType string, upload io.Reader) (ImportResult, error)
+	GetConsumerPrice(query string) ([]domain.Product, pricing.Quote, error)
+	GetExpiringProducts(query url.Values) ([]domain.Product, error)
+	GetExpiredProducts() ([]domain.Product, error)
+	UnpublishExpiredProducts() ([]domain.Product, error)
 }
 
 type serviceProduct struct {
-	repository repository.RepositoryProduct
+	repository   repository.RepositoryProduct
+	pricingRules *pricing.Rules
+	clock        Clock
 }
 
-func (sp *serviceProduct) GetProducts() ([]domain.Product, error) {
-	return sp.repository.GetProducts()
+// GetProducts returns the page of the catalog described by the limit, offset, cursor, sort and fields parameters.
+func (sp *serviceProduct) GetProducts(query url.Values) (ProductPage, error) {
+	pageQuery, err := ParseProductPageQuery(query)
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	products, err := sp.repository.GetProducts()
+	if err != nil {
+		return ProductPage{}, err
+	}
+
+	return pageQuery.Apply(products)
 }
 
 func (sp *serviceProduct) GetProductById(pathVariable string) (domain.Product, error) {
@@ -45,10 +79,10 @@ func (sp *serviceProduct) GetProductById(pathVariable string) (domain.Product, e
 	return product, nil
 }
 
-func (sp *serviceProduct) SearchProduct(query string) ([]domain.Product, error) {
-	priceGt, err := strconv.ParseFloat(query, 64)
+func (sp *serviceProduct) SearchProduct(query url.Values) ([]domain.Product, error) {
+	filter, err := ParseProductFilter(query)
 	if err != nil {
-		return nil, utility.ErrInvalidQuery
+		return nil, err
 	}
 
 	products, err := sp.repository.GetProducts()
@@ -59,7 +93,7 @@ func (sp *serviceProduct) SearchProduct(query string) ([]domain.Product, error)
 
 	var productsFiltered []domain.Product
 	for _, product := range products {
-		if product.Price > priceGt {
+		if filter.Match(product) {
 			productsFiltered = append(productsFiltered, product)
 		}
 	}
@@ -67,98 +101,144 @@ func (sp *serviceProduct) SearchProduct(query string) ([]domain.Product, error)
 	return productsFiltered, nil
 }
 
+// CreateProduct validates every field of reqProduct and stores it. Code value uniqueness is enforced by the repository,
+// atomically with the write.
 func (sp *serviceProduct) CreateProduct(reqProduct utility.ProductRequest) (domain.Product, error) {
-	products, err := sp.repository.GetProducts()
-
-	if err != nil {
+	if err := validation.ProductRequest(reqProduct); err != nil {
 		return domain.Product{}, err
 	}
 
-	switch {
-	case !reqProduct.VerifyNonZeroValues():
-		return domain.Product{}, utility.ErrInvalidValues
-	case !reqProduct.VerifyUniqueCodeValue(products):
-		return domain.Product{}, utility.ErrUniqueCodeValue
-	case !reqProduct.VerifyExpirationDate():
-		return domain.Product{}, utility.ErrInvalidDate
-	}
-
 	return sp.repository.CreateProduct(reqProduct)
 }
 
-func (sp *serviceProduct) UpdateProduct(pathVariable string, reqProduct utility.ProductRequest) (domain.Product, error) {
+// UpdateProduct replaces the product, checking ifMatch in the same transaction as the write.
+func (sp *serviceProduct) UpdateProduct(pathVariable string, reqProduct utility.ProductRequest, ifMatch string) (product domain.Product, err error) {
 
 	id, err := strconv.Atoi(pathVariable)
 	if err != nil {
 		return domain.Product{}, utility.ErrInvalidId
 	}
 
-	products, err := sp.repository.GetProducts()
-	if err != nil {
+	if err := validation.ProductRequest(reqProduct); err != nil {
 		return domain.Product{}, err
 	}
 
-	switch {
-	case !reqProduct.VerifyNonZeroValues():
-		return domain.Product{}, utility.ErrInvalidValues
-	case !reqProduct.VerifyUniqueCodeValue(products):
-		return domain.Product{}, utility.ErrUniqueCodeValue
-	case !reqProduct.VerifyExpirationDate():
-		return domain.Product{}, utility.ErrInvalidDate
+	err = sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+		if err := checkIfMatch(tx, id, ifMatch); err != nil {
+			return err
+		}
+
+		product, err = tx.UpdateProduct(id, reqProduct)
+		return err
+	})
+	if err != nil {
+		return domain.Product{}, err
 	}
 
-	return sp.repository.UpdateProduct(id, reqProduct)
+	return product, nil
 }
 
-func (sp *serviceProduct) DeleteProduct(pathVariable string) error {
+func (sp *serviceProduct) DeleteProduct(pathVariable string, ifMatch string) error {
 	id, err := strconv.Atoi(pathVariable)
 	if err != nil {
 		return utility.ErrInvalidId
 	}
 
-	return sp.repository.DeleteProduct(id)
+	return sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+		if err := checkIfMatch(tx, id, ifMatch); err != nil {
+			return err
+		}
+
+		return tx.DeleteProduct(id)
+	})
 }
 
-func (sp *serviceProduct) UpdatePatchProduct(pathVariable string, reqProduct utility.ProductPatchRequest) (domain.Product, error) {
+func (sp *serviceProduct) UpdatePatchProduct(pathVariable string, reqProduct utility.ProductPatchRequest, ifMatch string) (product domain.Product, err error) {
 	id, err := strconv.Atoi(pathVariable)
 	if err != nil {
 		return domain.Product{}, utility.ErrInvalidId
 	}
 
-	products, err := sp.repository.GetProducts()
+	if err := validation.ProductPatchRequest(reqProduct); err != nil {
+		return domain.Product{}, err
+	}
+
+	err = sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+		if err := checkIfMatch(tx, id, ifMatch); err != nil {
+			return err
+		}
+
+		product, err = tx.UpdatePatchProduct(id, reqProduct)
+		return err
+	})
 	if err != nil {
 		return domain.Product{}, err
 	}
 
-	switch {
-	case !reqProduct.VerifyNonZeroValues():
-		return domain.Product{}, utility.ErrInvalidValues
-	case !reqProduct.VerifyUniqueCodeValue(products):
-		return domain.Product{}, utility.ErrUniqueCodeValue
-	case !reqProduct.VerifyExpirationDate():
-		return domain.Product{}, utility.ErrInvalidDate
+	return product, nil
+}
+
+// ApplyProductPatch applies p to the JSON document of the product and stores the result once it passes
+// validation. The product is read, checked against ifMatch, patched and written in a single transaction.
+func (sp *serviceProduct) ApplyProductPatch(pathVariable string, p patch.Patch, ifMatch string) (product domain.Product, err error) {
+	id, err := strconv.Atoi(pathVariable)
+	if err != nil {
+		return domain.Product{}, utility.ErrInvalidId
+	}
+
+	err = sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+		original, err := tx.GetProductById(id)
+		if err != nil {
+			return err
+		}
+
+		if ifMatch != "" && !matchesIfMatch(ifMatch, original) {
+			return utility.ErrPreconditionFailed
+		}
+
+		doc, err := json.Marshal(original)
+		if err != nil {
+			return err
+		}
+
+		patched, err := p.Apply(doc)
+		if err != nil {
+			return err
+		}
+
+		reqProduct, err := validation.PatchedProduct(original, patched)
+		if err != nil {
+			return err
+		}
+
+		product, err = tx.UpdateProduct(id, reqProduct)
+		return err
+	})
+	if err != nil {
+		return domain.Product{}, err
 	}
 
-	return sp.repository.UpdatePatchProduct(id, reqProduct)
+	return product, nil
 }
 
-func (sp *serviceProduct) GetConsumerPrice(query string) ([]domain.Product, float64, error) {
+// GetConsumerPrice prices the products listed in query, e.g. "[1,2,2]", with the pricing rules of the service.
+// An empty query prices one unit of every product of the catalog.
+func (sp *serviceProduct) GetConsumerPrice(query string) ([]domain.Product, pricing.Quote, error) {
 
 	var products []domain.Product
-	var totalPrice float64
-	var totalProducts int
+	var lines []pricing.Line
 
 	if query == "" {
 		var err error
 		products, err = sp.repository.GetProducts()
 
 		if err != nil {
-			return nil, 0, err
+			return nil, pricing.Quote{}, err
 		}
 
+		sort.Slice(products, func(i, j int) bool { return products[i].Id < products[j].Id })
 		for _, product := range products {
-			totalPrice += product.Price
-			totalProducts++
+			lines = append(lines, pricing.Line{Product: product, Quantity: 1})
 		}
 
 	} else {
@@ -166,45 +246,88 @@ func (sp *serviceProduct) GetConsumerPrice(query string) ([]domain.Product, floa
 		values := strings.Split(rawValues, ",")
 		uniqueProductsIds := utility.CountValues(values)
 
-		products = []domain.Product{}
+		ids := make([]int, 0, len(uniqueProductsIds))
+		quantities := make(map[int]int, len(uniqueProductsIds))
 		for key, value := range uniqueProductsIds {
 			id, err := strconv.Atoi(key)
 			if err != nil {
-				return nil, 0, utility.ErrInvalidQuery
-			}
-
-			product, err := sp.repository.GetProductById(id)
-			if err != nil {
-				return nil, 0, err
-			}
-
-			if value > product.Quantity {
-				return nil, 0, utility.ErrInvalidQuery
-			}
-			if !product.IsPublished {
-				return nil, 0, utility.ErrInvalidQuery
+				return nil, pricing.Quote{}, utility.ErrInvalidQuery
 			}
+			ids = append(ids, id)
+			quantities[id] = value
+		}
+		sort.Ints(ids)
 
-			products = append(products, product)
-			totalPrice += product.Price * float64(value)
-			totalProducts += value
+		var err error
+		products, lines, err = availableLines(sp.repository.GetProductById, ids, quantities, sp.today())
+		// This endpoint has always answered unpublished and out of stock products as an invalid query.
+		if errors.Is(err, utility.ErrProductUnavailable) || errors.Is(err, utility.ErrInsufficientStock) {
+			return nil, pricing.Quote{}, utility.ErrInvalidQuery
+		}
+		if err != nil {
+			return nil, pricing.Quote{}, err
 		}
 	}
 
+	quote, err := sp.pricingRules.Evaluate(lines)
+	if err != nil {
+		return nil, pricing.Quote{}, err
+	}
+
+	return products, quote, nil
+}
+
+// checkAvailable returns an error when quantity units of product cannot be sold on today.
+func checkAvailable(product domain.Product, quantity int, today domain.Date) error {
 	switch {
-	case totalProducts <= 10:
-		totalPrice = totalPrice * 1.21
-	case totalProducts > 10 && totalProducts <= 20:
-		totalPrice = totalPrice * 1.17
-	case totalProducts > 20:
-		totalPrice = totalPrice * 1.15
+	case !product.IsPublished:
+		return fmt.Errorf("%w: product %d is not published", utility.ErrProductUnavailable, product.Id)
+	case IsExpired(product, today):
+		return fmt.Errorf("%w: product %d", utility.ErrProductExpired, product.Id)
+	case quantity > product.Quantity:
+		return fmt.Errorf("%w: product %d has %d units", utility.ErrInsufficientStock, product.Id, product.Quantity)
 	}
+	return nil
+}
 
-	return products, totalPrice, nil
+// availableLines returns the products of ids, read with getProduct, and their lines of quantities units.
+// It fails when a product does not exist or checkAvailable fails for it.
+func availableLines(getProduct func(id int) (domain.Product, error), ids []int, quantities map[int]int, today domain.Date) ([]domain.Product, []pricing.Line, error) {
+	products := make([]domain.Product, 0, len(ids))
+	lines := make([]pricing.Line, 0, len(ids))
+
+	for _, id := range ids {
+		product, err := getProduct(id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := checkAvailable(product, quantities[id], today); err != nil {
+			return nil, nil, err
+		}
+
+		products = append(products, product)
+		lines = append(lines, pricing.Line{Product: product, Quantity: quantities[id]})
+	}
+
+	return products, lines, nil
 }
 
-func NewServiceProduct(repository repository.RepositoryProduct) *serviceProduct {
+// NewServiceProduct creates the product service. A nil pricingRules uses pricing.DefaultRules.
+func NewServiceProduct(repository repository.RepositoryProduct, pricingRules *pricing.Rules) *serviceProduct {
+	if pricingRules == nil {
+		pricingRules = pricing.DefaultRules()
+	}
+
 	return &serviceProduct{
-		repository: repository,
+		repository:   repository,
+		pricingRules: pricingRules,
+		clock:        time.Now,
 	}
 }
+
+// WithClock makes the service use clock to tell the current date, e.g. to decide which products are expired.
+func (sp *serviceProduct) WithClock(clock Clock) *serviceProduct {
+	sp.clock = clock
+	return sp
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/validation"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// BulkMode tells how a bulk request handles the items that fail.
+type BulkMode string
+
+const (
+	// BulkAtomic applies every item or none: when an item fails, the others are reported with utility.ErrBulkAborted.
+	BulkAtomic BulkMode = "atomic"
+	// BulkBestEffort applies every item that succeeds and reports the others.
+	BulkBestEffort BulkMode = "best_effort"
+)
+
+// maxBulkItems bounds the size of a batch, which is applied in a single transaction.
+const maxBulkItems = 1000
+
+// BulkItemResult is the outcome of the item at Index of a bulk request.
+type BulkItemResult struct {
+	Index int
+	// Product is the stored product, nil when the item failed or deleted it.
+	Product *domain.Product
+	Err     error
+}
+
+// BulkResult holds the outcome of every item of a bulk request, in the order of the request.
+type BulkResult struct {
+	Mode  BulkMode
+	Items []BulkItemResult
+}
+
+// Failed returns the number of items that were not applied.
+func (br BulkResult) Failed() int {
+	failed := 0
+	for _, item := range br.Items {
+		if item.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// FirstError returns the error of the first item that failed on its own, that is without counting the items
+// aborted because of it, or nil when every item was applied.
+func (br BulkResult) FirstError() error {
+	for _, item := range br.Items {
+		if item.Err != nil && !errors.Is(item.Err, utility.ErrBulkAborted) {
+			return fmt.Errorf("item %d: %w", item.Index, item.Err)
+		}
+	}
+	return nil
+}
+
+// parseBulkMode reads the mode query parameter, BulkAtomic when missing.
+func parseBulkMode(query url.Values) (BulkMode, error) {
+	switch mode := BulkMode(query.Get("mode")); mode {
+	case "":
+		return BulkAtomic, nil
+	case BulkAtomic, BulkBestEffort:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: mode: expected %s or %s", utility.ErrInvalidQuery, BulkAtomic, BulkBestEffort)
+}
+
+// runBulk validates then applies n items, at most limit, in a single transaction, so the whole batch is
+// persisted at once. An item failing its validation is never applied. In BulkAtomic mode the first failure
+// rolls back the transaction, and every item that did not fail on its own is reported with utility.ErrBulkAborted.
+func (sp *serviceProduct) runBulk(query url.Values, n int, limit int, validate func(i int) error,
+	apply func(tx repository.RepositoryProductTx, i int) (*domain.Product, error)) (BulkResult, error) {
+
+	mode, err := parseBulkMode(query)
+	if err != nil {
+		return BulkResult{}, err
+	}
+	if n == 0 {
+		return BulkResult{}, fmt.Errorf("%w: the batch is empty", utility.ErrInvalidRequestBody)
+	}
+	if n > limit {
+		return BulkResult{}, fmt.Errorf("%w: at most %d items", utility.ErrBulkTooLarge, limit)
+	}
+
+	result := BulkResult{Mode: mode, Items: make([]BulkItemResult, n)}
+	invalid := false
+	for i := range result.Items {
+		result.Items[i].Index = i
+		if err := validate(i); err != nil {
+			result.Items[i].Err = err
+			invalid = true
+		}
+	}
+
+	// rolledBack stops the transaction of an atomic batch once an item failed.
+	rolledBack := errors.New("batch rolled back")
+
+	if invalid && mode == BulkAtomic {
+		err = rolledBack
+	} else {
+		err = sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+			for i := range result.Items {
+				if result.Items[i].Err != nil {
+					continue
+				}
+
+				product, err := apply(tx, i)
+				if err != nil {
+					result.Items[i].Err = err
+					if mode == BulkAtomic {
+						return rolledBack
+					}
+					continue
+				}
+				result.Items[i].Product = product
+			}
+			return nil
+		})
+	}
+
+	if errors.Is(err, rolledBack) {
+		for i := range result.Items {
+			if result.Items[i].Err == nil {
+				result.Items[i] = BulkItemResult{Index: i, Err: utility.ErrBulkAborted}
+			}
+		}
+		return result, nil
+	}
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	return result, nil
+}
+
+// CreateProducts creates every product of reqProducts, following the mode query parameter.
+func (sp *serviceProduct) CreateProducts(query url.Values, reqProducts []utility.ProductRequest) (BulkResult, error) {
+	return sp.runBulk(query, len(reqProducts), maxBulkItems,
+		func(i int) error {
+			return validation.ProductRequest(reqProducts[i])
+		},
+		func(tx repository.RepositoryProductTx, i int) (*domain.Product, error) {
+			product, err := tx.CreateProduct(reqProducts[i])
+			if err != nil {
+				return nil, err
+			}
+			return &product, nil
+		})
+}
+
+// PatchProducts patches or deletes the product of every item, following the mode query parameter.
+func (sp *serviceProduct) PatchProducts(query url.Values, items []utility.ProductBulkPatchItem) (BulkResult, error) {
+	return sp.runBulk(query, len(items), maxBulkItems,
+		func(i int) error {
+			return validation.ProductBulkPatchItem(items[i])
+		},
+		func(tx repository.RepositoryProductTx, i int) (*domain.Product, error) {
+			item := items[i]
+
+			if item.Version != nil {
+				current, err := tx.GetProductById(item.Id)
+				if err != nil {
+					return nil, err
+				}
+				if current.Version != *item.Version {
+					return nil, utility.ErrPreconditionFailed
+				}
+			}
+
+			if item.Delete {
+				return nil, tx.DeleteProduct(item.Id)
+			}
+
+			product, err := tx.UpdatePatchProduct(item.Id, item.ProductPatchRequest)
+			if err != nil {
+				return nil, err
+			}
+			return &product, nil
+		})
+}
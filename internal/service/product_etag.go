@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// entityTag is one entity tag of an If-Match or If-None-Match header.
+type entityTag struct {
+	weak bool
+	// opaque is the quoted tag, e.g. `"3"`.
+	opaque string
+}
+
+// parseEntityTags parses the value of an If-Match or If-None-Match header, a list of entity tags or "*".
+// Parsing stops at the first malformed tag, so only the well formed ones before it are returned.
+func parseEntityTags(header string) (tags []entityTag, wildcard bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return nil, true
+	}
+
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			return tags, false
+		}
+
+		var tag entityTag
+		if strings.HasPrefix(header, "W/") {
+			tag.weak = true
+			header = header[2:]
+		}
+
+		if !strings.HasPrefix(header, `"`) {
+			return tags, false
+		}
+		end := strings.IndexByte(header[1:], '"')
+		if end < 0 {
+			return tags, false
+		}
+
+		tag.opaque = header[:end+2]
+		tags = append(tags, tag)
+		header = header[end+2:]
+	}
+}
+
+// matchesIfMatch reports whether product satisfies the If-Match header value ifMatch: "*" matches wildcard
+// product, otherwise one of the tags must be strong and equal to the ETag of product.
+func matchesIfMatch(ifMatch string, product domain.Product) bool {
+	tags, wildcard := parseEntityTags(ifMatch)
+	if wildcard {
+		return true
+	}
+
+	for _, tag := range tags {
+		if !tag.weak && tag.opaque == product.ETag() {
+			return true
+		}
+	}
+	return false
+}
+
+// NotModified reports whether the If-None-Match header value ifNoneMatch matches product, in which case
+// a read can be answered with 304 Not Modified. Tags are compared ignoring whether they are weak.
+func NotModified(ifNoneMatch string, product domain.Product) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	tags, wildcard := parseEntityTags(ifNoneMatch)
+	if wildcard {
+		return true
+	}
+
+	for _, tag := range tags {
+		if tag.opaque == product.ETag() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch fails with utility.ErrPreconditionFailed when the If-Match header value ifMatch is given
+// and does not match the product id as read in tx, so the write that follows in tx cannot overwrite
+// a version the client has not seen.
+func checkIfMatch(tx repository.RepositoryProductTx, id int, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	product, err := tx.GetProductById(id)
+	if err != nil {
+		return err
+	}
+
+	if !matchesIfMatch(ifMatch, product) {
+		return utility.ErrPreconditionFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Clock returns the current time. It is injected into the service so tests can fix "today".
+type Clock func() time.Time
+
+// defaultExpiringWithin is the window of GetExpiringProducts when the within parameter is missing.
+const defaultExpiringWithin = 7
+
+// IsExpired reports whether product expired before today. A product is still valid on its expiration day,
+// and a product without an expiration never expires.
+func IsExpired(product domain.Product, today domain.Date) bool {
+	return !product.Expiration.IsZero() && product.Expiration.Before(today)
+}
+
+// parseWithinDays parses a window such as "7d", "2w" or "10" (days) into a number of days.
+func parseWithinDays(value string) (int, error) {
+	if value == "" {
+		return defaultExpiringWithin, nil
+	}
+
+	unit := 1
+	switch {
+	case strings.HasSuffix(value, "d"):
+		value = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		value, unit = strings.TrimSuffix(value, "w"), 7
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: within must be a number of days like 7d or weeks like 2w", utility.ErrInvalidQuery)
+	}
+
+	return n * unit, nil
+}
+
+// today returns the current date of the service clock.
+func (sp *serviceProduct) today() domain.Date {
+	return domain.DateOf(sp.clock())
+}
+
+// GetExpiringProducts returns the products that are not expired yet but expire within the window given by the
+// within parameter, e.g. "7d", sorted by expiration.
+func (sp *serviceProduct) GetExpiringProducts(query url.Values) ([]domain.Product, error) {
+	days, err := parseWithinDays(query.Get("within"))
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := sp.repository.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	today := sp.today()
+	limit := today.AddDays(days)
+
+	expiring := []domain.Product{}
+	for _, product := range products {
+		if !product.Expiration.IsZero() && !IsExpired(product, today) && !product.Expiration.After(limit) {
+			expiring = append(expiring, product)
+		}
+	}
+	sortByExpiration(expiring)
+
+	return expiring, nil
+}
+
+// GetExpiredProducts returns the expired products, published or not, sorted by expiration.
+func (sp *serviceProduct) GetExpiredProducts() ([]domain.Product, error) {
+	products, err := sp.repository.GetProducts()
+	if err != nil {
+		return nil, err
+	}
+
+	today := sp.today()
+
+	expired := []domain.Product{}
+	for _, product := range products {
+		if IsExpired(product, today) {
+			expired = append(expired, product)
+		}
+	}
+	sortByExpiration(expired)
+
+	return expired, nil
+}
+
+// UnpublishExpiredProducts unpublishes every published product that expired, in one transaction,
+// and returns the products it changed.
+func (sp *serviceProduct) UnpublishExpiredProducts() ([]domain.Product, error) {
+	today := sp.today()
+	unpublished := false
+
+	var changed []domain.Product
+	err := sp.repository.WithTx(func(tx repository.RepositoryProductTx) error {
+		changed = nil
+
+		products, err := tx.GetProducts()
+		if err != nil {
+			return err
+		}
+
+		for _, product := range products {
+			if !product.IsPublished || !IsExpired(product, today) {
+				continue
+			}
+
+			product, err = tx.UpdatePatchProduct(product.Id, utility.ProductPatchRequest{IsPublished: &unpublished})
+			if err != nil {
+				return err
+			}
+			changed = append(changed, product)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Id < changed[j].Id })
+	return changed, nil
+}
+
+func sortByExpiration(products []domain.Product) {
+	sort.SliceStable(products, func(i, j int) bool {
+		if c := products[i].Expiration.Compare(products[j].Expiration); c != 0 {
+			return c < 0
+		}
+		return products[i].Id < products[j].Id
+	})
+}
+
+// ExpiryScheduler periodically unpublishes the expired products of a service.
+type ExpiryScheduler struct {
+	service  ServiceProduct
+	interval time.Duration
+}
+
+// NewExpiryScheduler creates a scheduler that checks the products of service every interval.
+func NewExpiryScheduler(service ServiceProduct, interval time.Duration) *ExpiryScheduler {
+	return &ExpiryScheduler{
+		service:  service,
+		interval: interval,
+	}
+}
+
+// Run checks the products right away and then every interval, until ctx is done.
+// Failed checks are logged and retried on the next tick.
+func (es *ExpiryScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(es.interval)
+	defer ticker.Stop()
+
+	for {
+		es.check()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (es *ExpiryScheduler) check() {
+	products, err := es.service.UnpublishExpiredProducts()
+	if err != nil {
+		log.Printf("expiry scheduler: %v", err)
+		return
+	}
+
+	for _, product := range products {
+		log.Printf("expiry scheduler: unpublished product %d, expired on %s", product.Id, product.Expiration)
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// productPredicate reports whether a product satisfies a single filter.
+type productPredicate func(product domain.Product) bool
+
+// ProductFilter is the set of conditions of a /products/search query.
+// Conditions are combined with AND unless the query has op=or. op applies to every condition of the query:
+// filters cannot be grouped, so a query such as a AND (b OR c) cannot be expressed.
+type ProductFilter struct {
+	predicates []productPredicate
+	any        bool
+}
+
+// Match reports whether product satisfies the filter.
+func (f ProductFilter) Match(product domain.Product) bool {
+	for _, predicate := range f.predicates {
+		if predicate(product) == f.any {
+			return f.any
+		}
+	}
+	return !f.any
+}
+
+// filterParsers maps every supported query parameter to the parser of its value.
+var filterParsers = map[string]func(value string) (productPredicate, error){
+	"priceGt": func(value string) (productPredicate, error) {
+		price, err := domain.ParseMoney(value, "")
+		if err != nil {
+			return nil, fmt.Errorf("expected an amount")
+		}
+		return func(p domain.Product) bool {
+			return p.Price.Currency == price.Currency && p.Price.Amount > price.Amount
+		}, nil
+	},
+	"priceLt": func(value string) (productPredicate, error) {
+		price, err := domain.ParseMoney(value, "")
+		if err != nil {
+			return nil, fmt.Errorf("expected an amount")
+		}
+		return func(p domain.Product) bool {
+			return p.Price.Currency == price.Currency && p.Price.Amount < price.Amount
+		}, nil
+	},
+	"priceBetween": func(value string) (productPredicate, error) {
+		bounds, err := parseBounds(value, func(s string) (domain.Money, error) { return domain.ParseMoney(s, "") }, domain.Money.Compare)
+		if err != nil {
+			return nil, err
+		}
+		if bounds[0].Currency != bounds[1].Currency {
+			return nil, fmt.Errorf("bounds must be in the same currency")
+		}
+		return func(p domain.Product) bool {
+			return p.Price.Currency == bounds[0].Currency && p.Price.Amount >= bounds[0].Amount && p.Price.Amount <= bounds[1].Amount
+		}, nil
+	},
+	"quantityGt": func(value string) (productPredicate, error) {
+		quantity, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return func(p domain.Product) bool { return p.Quantity > quantity }, nil
+	},
+	"quantityLt": func(value string) (productPredicate, error) {
+		quantity, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return func(p domain.Product) bool { return p.Quantity < quantity }, nil
+	},
+	"quantityBetween": func(value string) (productPredicate, error) {
+		bounds, err := parseBounds(value, strconv.Atoi, cmp.Compare[int])
+		if err != nil {
+			return nil, err
+		}
+		return func(p domain.Product) bool { return p.Quantity >= bounds[0] && p.Quantity <= bounds[1] }, nil
+	},
+	"isPublished": func(value string) (productPredicate, error) {
+		isPublished, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return func(p domain.Product) bool { return p.IsPublished == isPublished }, nil
+	},
+	"expirationBefore": func(value string) (productPredicate, error) {
+		date, err := domain.ParseDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected a date formatted as DD/MM/YYYY or YYYY-MM-DD")
+		}
+		return func(p domain.Product) bool { return !p.Expiration.IsZero() && p.Expiration.Before(date) }, nil
+	},
+	"expirationAfter": func(value string) (productPredicate, error) {
+		date, err := domain.ParseDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected a date formatted as DD/MM/YYYY or YYYY-MM-DD")
+		}
+		return func(p domain.Product) bool { return p.Expiration.After(date) }, nil
+	},
+	"nameContains": func(value string) (productPredicate, error) {
+		value = strings.ToLower(value)
+		return func(p domain.Product) bool { return strings.Contains(strings.ToLower(p.Name), value) }, nil
+	},
+	"namePrefix": func(value string) (productPredicate, error) {
+		value = strings.ToLower(value)
+		return func(p domain.Product) bool { return strings.HasPrefix(strings.ToLower(p.Name), value) }, nil
+	},
+	"codeValue": func(value string) (productPredicate, error) {
+		return func(p domain.Product) bool { return p.CodeValue == value }, nil
+	},
+	"codeValuePrefix": func(value string) (productPredicate, error) {
+		return func(p domain.Product) bool { return strings.HasPrefix(p.CodeValue, value) }, nil
+	},
+}
+
+// parseBounds parses a "min,max" range.
+func parseBounds[T any](value string, parse func(string) (T, error), compare func(a, b T) int) ([2]T, error) {
+	var bounds [2]T
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return bounds, fmt.Errorf("expected two comma separated values")
+	}
+
+	for i, part := range parts {
+		bound, err := parse(strings.TrimSpace(part))
+		if err != nil {
+			return bounds, fmt.Errorf("%q is not a valid bound", part)
+		}
+		bounds[i] = bound
+	}
+
+	if compare(bounds[0], bounds[1]) > 0 {
+		return bounds, fmt.Errorf("lower bound is greater than upper bound")
+	}
+
+	return bounds, nil
+}
+
+// ParseProductFilter builds a ProductFilter from the query parameters of a search request.
+// Every error wraps utility.ErrInvalidQuery and names the offending parameter.
+func ParseProductFilter(query url.Values) (ProductFilter, error) {
+	var filter ProductFilter
+
+	// Sort the parameters so the reported error does not depend on map order.
+	params := make([]string, 0, len(query))
+	for param := range query {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+
+	for _, param := range params {
+		values := query[param]
+
+		if param == "op" {
+			if len(values) > 1 {
+				return ProductFilter{}, fmt.Errorf("%w: op: expected a single value", utility.ErrInvalidQuery)
+			}
+			switch strings.ToLower(values[0]) {
+			case "and":
+			case "or":
+				filter.any = true
+			default:
+				return ProductFilter{}, fmt.Errorf("%w: op: expected and or or", utility.ErrInvalidQuery)
+			}
+			continue
+		}
+
+		parse, ok := filterParsers[param]
+		if !ok {
+			return ProductFilter{}, fmt.Errorf("%w: unknown parameter %s", utility.ErrInvalidQuery, param)
+		}
+
+		for _, value := range values {
+			predicate, err := parse(value)
+			if err != nil {
+				return ProductFilter{}, fmt.Errorf("%w: %s: %v", utility.ErrInvalidQuery, param, err)
+			}
+			filter.predicates = append(filter.predicates, predicate)
+		}
+	}
+
+	if len(filter.predicates) == 0 {
+		return ProductFilter{}, fmt.Errorf("%w: at least one filter is required", utility.ErrInvalidQuery)
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// productFields lists the json fields of domain.Product, usable in sort and fields.
+var productFields = []string{"id", "name", "quantity", "code_value", "is_published", "expiration", "price", "version"}
+
+// productComparators compares two products on a single json field.
+var productComparators = map[string]func(a, b domain.Product) int{
+	"id":         func(a, b domain.Product) int { return cmp.Compare(a.Id, b.Id) },
+	"name":       func(a, b domain.Product) int { return strings.Compare(a.Name, b.Name) },
+	"quantity":   func(a, b domain.Product) int { return cmp.Compare(a.Quantity, b.Quantity) },
+	"code_value": func(a, b domain.Product) int { return strings.Compare(a.CodeValue, b.CodeValue) },
+	"is_published": func(a, b domain.Product) int {
+		return cmp.Compare(boolToInt(a.IsPublished), boolToInt(b.IsPublished))
+	},
+	"expiration": func(a, b domain.Product) int { return a.Expiration.Compare(b.Expiration) },
+	"price":      func(a, b domain.Product) int { return a.Price.Compare(b.Price) },
+	"version":    func(a, b domain.Product) int { return cmp.Compare(a.Version, b.Version) },
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type sortField struct {
+	name string
+	desc bool
+}
+
+// ProductPageQuery describes which products of the catalog to return and how.
+type ProductPageQuery struct {
+	// Limit is the maximum number of products of the page, 0 meaning no limit.
+	Limit  int
+	Offset int
+	// Cursor is the opaque position returned as NextCursor or PrevCursor of a previous page.
+	Cursor string
+	// Sort is the raw sort parameter, e.g. "price,-name".
+	Sort string
+	// Fields are the json fields to return, all of them when empty.
+	Fields []string
+
+	sortFields []sortField
+}
+
+// ProductPage is a window of the sorted catalog.
+type ProductPage struct {
+	Products []domain.Product
+	// Total is the number of products in the catalog.
+	Total  int
+	Limit  int
+	Offset int
+	// NextCursor and PrevCursor locate the following and preceding pages, empty when there is none.
+	NextCursor string
+	PrevCursor string
+	Fields     []string
+}
+
+// pageCursor is the decoded form of a cursor: the sort key of a product and the direction to read from it.
+type pageCursor struct {
+	Sort   string         `json:"s"`
+	Key    domain.Product `json:"k"`
+	Before bool           `json:"b,omitempty"`
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (pageCursor, error) {
+	var cursor pageCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// ParseProductPageQuery reads limit, offset, cursor, sort and fields from the query parameters.
+// Every error wraps utility.ErrInvalidQuery and names the offending parameter.
+func ParseProductPageQuery(query url.Values) (ProductPageQuery, error) {
+	var pq ProductPageQuery
+	var err error
+
+	if raw := query.Get("limit"); raw != "" {
+		if pq.Limit, err = strconv.Atoi(raw); err != nil || pq.Limit <= 0 {
+			return ProductPageQuery{}, fmt.Errorf("%w: limit: expected a positive integer", utility.ErrInvalidQuery)
+		}
+	}
+
+	if raw := query.Get("offset"); raw != "" {
+		if pq.Offset, err = strconv.Atoi(raw); err != nil || pq.Offset < 0 {
+			return ProductPageQuery{}, fmt.Errorf("%w: offset: expected a non negative integer", utility.ErrInvalidQuery)
+		}
+	}
+
+	pq.Cursor = query.Get("cursor")
+	if pq.Cursor != "" && query.Has("offset") {
+		return ProductPageQuery{}, fmt.Errorf("%w: cursor: cannot be combined with offset", utility.ErrInvalidQuery)
+	}
+
+	pq.Sort = query.Get("sort")
+	if pq.Sort != "" {
+		for _, name := range strings.Split(pq.Sort, ",") {
+			field := sortField{name: strings.TrimSpace(name)}
+			if strings.HasPrefix(field.name, "-") {
+				field.name, field.desc = field.name[1:], true
+			}
+			if _, ok := productComparators[field.name]; !ok {
+				return ProductPageQuery{}, fmt.Errorf("%w: sort: unknown field %q", utility.ErrInvalidQuery, field.name)
+			}
+			pq.sortFields = append(pq.sortFields, field)
+		}
+	}
+
+	if raw := query.Get("fields"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			if !slices.Contains(productFields, name) {
+				return ProductPageQuery{}, fmt.Errorf("%w: fields: unknown field %q", utility.ErrInvalidQuery, name)
+			}
+			pq.Fields = append(pq.Fields, name)
+		}
+	}
+
+	return pq, nil
+}
+
+// compare orders products by the sort fields, then by id so the order is total and stable.
+func (pq ProductPageQuery) compare(a, b domain.Product) int {
+	for _, field := range pq.sortFields {
+		c := productComparators[field.name](a, b)
+		if field.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.Id, b.Id)
+}
+
+// Apply sorts products and cuts the page described by pq.
+func (pq ProductPageQuery) Apply(products []domain.Product) (ProductPage, error) {
+	slices.SortFunc(products, pq.compare)
+
+	page := ProductPage{
+		Total:  len(products),
+		Limit:  pq.Limit,
+		Fields: pq.Fields,
+	}
+
+	start, end := 0, len(products)
+	if pq.Cursor == "" {
+		start = min(pq.Offset, len(products))
+		if pq.Limit > 0 {
+			end = min(start+pq.Limit, len(products))
+		}
+	} else {
+		cursor, err := decodeCursor(pq.Cursor)
+		if err != nil || cursor.Sort != pq.Sort {
+			return ProductPage{}, fmt.Errorf("%w: cursor: malformed or issued for another sort", utility.ErrInvalidQuery)
+		}
+
+		// position is the index of the first product sorted at or after the cursor key.
+		position, found := slices.BinarySearchFunc(products, cursor.Key, pq.compare)
+		if cursor.Before {
+			end = position
+			if pq.Limit > 0 {
+				start = max(end-pq.Limit, 0)
+			}
+		} else {
+			start = position
+			if found {
+				start++
+			}
+			if pq.Limit > 0 {
+				end = min(start+pq.Limit, len(products))
+			}
+		}
+	}
+
+	page.Products = products[start:end]
+	page.Offset = start
+
+	if end > 0 && end < len(products) {
+		page.NextCursor = encodeCursor(pageCursor{Sort: pq.Sort, Key: products[end-1]})
+	}
+	if start > 0 && start < len(products) {
+		page.PrevCursor = encodeCursor(pageCursor{Sort: pq.Sort, Key: products[start], Before: true})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/MDavidCV/go-web-module/internal/catalog"
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// ProductStream lists the whole catalog in id order without loading it in memory.
+type ProductStream struct {
+	// Format is the format the products are written in. A JSON array is written as it is read.
+	Format     catalog.Format
+	repository repository.RepositoryProduct
+}
+
+// Each calls fn with every product of the catalog in id order, stopping at the first error of fn.
+func (ps ProductStream) Each(fn func(product domain.Product) error) error {
+	return ps.repository.IterateProducts(fn)
+}
+
+// StreamProducts reads the stream query parameter, ndjson or json, of a streamed list. Streams always list
+// every product in id order, so they cannot be combined with the pagination parameters of GetProducts.
+func (sp *serviceProduct) StreamProducts(query url.Values) (ProductStream, error) {
+	format := catalog.Format(query.Get("stream"))
+	if format != catalog.FormatNDJSON && format != catalog.FormatJSON {
+		return ProductStream{}, fmt.Errorf("%w: stream: expected ndjson or json", utility.ErrInvalidQuery)
+	}
+
+	for _, param := range []string{"limit", "offset", "cursor", "sort", "fields"} {
+		if query.Has(param) {
+			return ProductStream{}, fmt.Errorf("%w: %s: cannot be combined with stream", utility.ErrInvalidQuery, param)
+		}
+	}
+
+	return ProductStream{Format: format, repository: sp.repository}, nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"io"
+	"net/url"
+
+	"github.com/MDavidCV/go-web-module/internal/catalog"
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/repository"
+	"github.com/MDavidCV/go-web-module/internal/validation"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// maxImportRows bounds the size of an import, which is applied in a single transaction like a bulk request.
+const maxImportRows = 10000
+
+// ImportResult is the outcome of every row of an import.
+type ImportResult struct {
+	BulkResult
+	Format catalog.Format
+	// Lines holds the line of the upload of each item of BulkResult.
+	Lines []int
+}
+
+// ExportProducts streams the whole catalog in id order, with the format given by the format query parameter.
+func (sp *serviceProduct) ExportProducts(query url.Values) (ProductStream, error) {
+	format, err := catalog.ParseFormat(query.Get("format"))
+	if err != nil {
+		return ProductStream{}, err
+	}
+
+	return ProductStream{Format: format, repository: sp.repository}, nil
+}
+
+// ImportProducts creates the product of every row of upload, a CSV or NDJSON document as given by mediaType,
+// following the mode query parameter like CreateProducts. Rows that cannot be decoded or break a product rule
+// are reported with their violations.
+func (sp *serviceProduct) ImportProducts(query url.Values, mediaType string, upload io.Reader) (ImportResult, error) {
+	format, ok := catalog.FormatOfMediaType(mediaType)
+	if !ok || format == catalog.FormatJSON {
+		return ImportResult{}, utility.ErrUnsupportedMediaType
+	}
+
+	rows, err := catalog.ReadRows(upload, format, maxImportRows)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result, err := sp.runBulk(query, len(rows), maxImportRows,
+		func(i int) error {
+			if rows[i].Err != nil {
+				return rows[i].Err
+			}
+			return validation.ProductRequest(rows[i].Product)
+		},
+		func(tx repository.RepositoryProductTx, i int) (*domain.Product, error) {
+			product, err := tx.CreateProduct(rows[i].Product)
+			if err != nil {
+				return nil, err
+			}
+			return &product, nil
+		})
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	lines := make([]int, len(rows))
+	for i, row := range rows {
+		lines[i] = row.Line
+	}
+
+	return ImportResult{BulkResult: result, Format: format, Lines: lines}, nil
+}
@@ -0,0 +1,253 @@
+// Package tlsconfig builds the TLS configuration of the server, reloading its certificates when their files change.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cipher policies, the TLS 1.2 cipher suites accepted. TLS 1.3 suites are not configurable and always secure.
+const (
+	// CipherPolicyModern only accepts forward secret AEAD suites.
+	CipherPolicyModern = "modern"
+	// CipherPolicyCompatible also accepts the forward secret CBC suites of older clients.
+	CipherPolicyCompatible = "compatible"
+)
+
+// Client certificate modes.
+const (
+	// ClientAuthOptional verifies the certificates clients send, but lets clients without one connect.
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects clients without a valid certificate.
+	ClientAuthRequire = "require"
+)
+
+var minVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var cipherPolicies = map[string][]uint16{
+	CipherPolicyModern: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+	},
+	CipherPolicyCompatible: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	},
+}
+
+// Config holds the TLS settings of the server.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3", "1.2" when empty.
+	MinVersion string
+	// CipherPolicy is one of the cipher policies, CipherPolicyModern when empty.
+	CipherPolicy string
+	// ClientCAFile is the PEM bundle of the CAs client certificates are verified against. Clients are not asked for
+	// a certificate when empty.
+	ClientCAFile string
+	// ClientAuth is one of the client certificate modes, ClientAuthOptional when empty.
+	ClientAuth string
+}
+
+// files are the files of the certificates, and when they were last modified.
+type files struct {
+	cert, key, clientCA time.Time
+}
+
+// reloader serves the certificate and client CAs of its files, reloading them when the files change.
+type reloader struct {
+	cfg  Config
+	base *tls.Config
+
+	// mu guards the loaded certificates.
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	loaded   files
+}
+
+// New checks cfg and loads its certificates.
+func New(cfg Config) (*reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("tls: a certificate and a key file are required")
+	}
+
+	if cfg.MinVersion == "" {
+		cfg.MinVersion = "1.2"
+	}
+	minVersion, ok := minVersions[cfg.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("tls: unknown minimum version %q", cfg.MinVersion)
+	}
+
+	if cfg.CipherPolicy == "" {
+		cfg.CipherPolicy = CipherPolicyModern
+	}
+	cipherSuites, ok := cipherPolicies[strings.ToLower(cfg.CipherPolicy)]
+	if !ok {
+		return nil, fmt.Errorf("tls: unknown cipher policy %q", cfg.CipherPolicy)
+	}
+
+	clientAuth := tls.NoClientCert
+	if cfg.ClientCAFile != "" {
+		switch strings.ToLower(cfg.ClientAuth) {
+		case "", ClientAuthOptional:
+			clientAuth = tls.VerifyClientCertIfGiven
+		case ClientAuthRequire:
+			clientAuth = tls.RequireAndVerifyClientCert
+		default:
+			return nil, fmt.Errorf("tls: unknown client auth mode %q", cfg.ClientAuth)
+		}
+	}
+
+	r := &reloader{
+		cfg: cfg,
+		base: &tls.Config{
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+			ClientAuth:   clientAuth,
+			// The configuration of each connection replaces the one http.Server adds h2 to, so it is offered here.
+			NextProtos: []string{"h2", "http/1.1"},
+		},
+	}
+
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the configuration of the server. Each connection gets the certificates loaded last.
+func (r *reloader) TLSConfig() *tls.Config {
+	config := r.base.Clone()
+	// GetCertificate is only set for http.Server, which requires a certificate source it knows of.
+	config.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		return r.cert, nil
+	}
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		config := r.base.Clone()
+		config.Certificates = []tls.Certificate{*r.cert}
+		config.ClientCAs = r.clientCA
+		return config, nil
+	}
+	return config
+}
+
+// Reload loads the certificates again when a file changed since the last load, and reports whether it did.
+// The certificates loaded last are kept when the new ones are invalid, e.g. when a file is being written.
+func (r *reloader) Reload() (bool, error) {
+	modified, err := r.modTimes()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modified == r.loaded
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("tls: %w", err)
+	}
+
+	var clientCA *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("tls: %w", err)
+		}
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(data) {
+			return false, fmt.Errorf("tls: no certificate in client CA bundle %s", r.cfg.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.clientCA, r.loaded = &cert, clientCA, modified
+
+	return true, nil
+}
+
+func (r *reloader) modTimes() (files, error) {
+	var modified files
+	targets := []struct {
+		filename string
+		modTime  *time.Time
+	}{
+		{r.cfg.CertFile, &modified.cert},
+		{r.cfg.KeyFile, &modified.key},
+		{r.cfg.ClientCAFile, &modified.clientCA},
+	}
+
+	for _, target := range targets {
+		if target.filename == "" {
+			continue
+		}
+
+		info, err := os.Stat(target.filename)
+		if err != nil {
+			return files{}, fmt.Errorf("tls: %w", err)
+		}
+		*target.modTime = info.ModTime()
+	}
+	return modified, nil
+}
+
+// Watch checks the files every interval, reloading the certificates when they change, until ctx is done.
+// Failed reloads are logged and retried on the next tick.
+func (r *reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			log.Printf("tls reloader: %v", err)
+			continue
+		}
+		if reloaded {
+			log.Printf("tls reloader: reloaded the certificates of %s", r.cfg.CertFile)
+		}
+	}
+}
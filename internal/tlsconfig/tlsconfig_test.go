@@ -0,0 +1,259 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/internal/tlsconfig"
+	"github.com/stretchr/testify/require"
+)
+
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue returns a certificate for subject signed by parent, or self signed when parent is nil.
+func issue(t *testing.T, subject pkix.Name, parent *certificate, usage x509.ExtKeyUsage) certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate{cert: cert, key: key}
+}
+
+// write writes the PEM certificate and key of c in dir and returns their paths.
+func write(t *testing.T, dir string, c certificate) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := write(t, dir, issue(t, pkix.Name{CommonName: "localhost"}, nil, x509.ExtKeyUsageServerAuth))
+
+	cases := []struct {
+		name string
+		cfg  tlsconfig.Config
+	}{
+		{"should require a certificate", tlsconfig.Config{KeyFile: keyFile}},
+		{"should reject unknown versions", tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"}},
+		{"should reject unknown cipher policies", tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, CipherPolicy: "legacy"}},
+		{"should reject unknown client auth modes", tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: "maybe"}},
+		{"should reject a missing key", tlsconfig.Config{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Act
+			_, err := tlsconfig.New(c.cfg)
+
+			// Assert
+			require.Error(t, err)
+		})
+	}
+
+	t.Run("sucess should apply the minimum version and cipher policy", func(t *testing.T) {
+		// Act
+		certificates, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"})
+
+		// Assert
+		require.NoError(t, err)
+		config := certificates.TLSConfig()
+		require.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+		require.Contains(t, config.CipherSuites, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256)
+		require.NotContains(t, config.CipherSuites, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA)
+	})
+}
+
+func TestHTTP2(t *testing.T) {
+	t.Run("sucess should negotiate HTTP/2 with http.Server", func(t *testing.T) {
+		// Arrange
+		ca := issue(t, pkix.Name{CommonName: "Test CA"}, nil, x509.ExtKeyUsageAny)
+		certFile, keyFile := write(t, t.TempDir(), issue(t, pkix.Name{CommonName: "localhost"}, &ca, x509.ExtKeyUsageServerAuth))
+		certificates, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		server := &http.Server{
+			Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			TLSConfig: certificates.TLSConfig(),
+		}
+		go server.ServeTLS(listener, "", "")
+		t.Cleanup(func() { server.Close() })
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+
+		// Act
+		response, err := client.Get("https://" + listener.Addr().String())
+
+		// Assert
+		require.NoError(t, err)
+		defer response.Body.Close()
+		require.Equal(t, 2, response.ProtoMajor)
+	})
+}
+
+func TestReload(t *testing.T) {
+	t.Run("sucess should serve the new certificate once its files changed", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		certFile, keyFile := write(t, dir, issue(t, pkix.Name{CommonName: "old"}, nil, x509.ExtKeyUsageServerAuth))
+		certificates, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+		getCertificate := certificates.TLSConfig().GetCertificate
+
+		unchanged, err := certificates.Reload()
+		require.NoError(t, err)
+
+		write(t, dir, issue(t, pkix.Name{CommonName: "new"}, nil, x509.ExtKeyUsageServerAuth))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, later, later))
+
+		// Act
+		reloaded, err := certificates.Reload()
+
+		// Assert
+		require.NoError(t, err)
+		require.False(t, unchanged)
+		require.True(t, reloaded)
+		served, err := getCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(served.Certificate[0])
+		require.NoError(t, err)
+		require.Equal(t, "new", leaf.Subject.CommonName)
+	})
+
+	t.Run("should keep the loaded certificate when the new files are invalid", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		certFile, keyFile := write(t, dir, issue(t, pkix.Name{CommonName: "old"}, nil, x509.ExtKeyUsageServerAuth))
+		certificates, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(certFile, []byte("partial"), 0o600))
+
+		// Act
+		_, err = certificates.Reload()
+
+		// Assert
+		require.Error(t, err)
+		served, err := certificates.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		require.NotNil(t, served)
+	})
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, pkix.Name{CommonName: "Test CA"}, nil, x509.ExtKeyUsageAny)
+	caFile, _ := write(t, t.TempDir(), ca)
+	certFile, keyFile := write(t, dir, issue(t, pkix.Name{CommonName: "localhost"}, &ca, x509.ExtKeyUsageServerAuth))
+	client := issue(t, pkix.Name{CommonName: "partner-1", Organization: []string{"Acme"}}, &ca, x509.ExtKeyUsageClientAuth)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	identities := auth.CertIdentities{"CN=partner-1,O=Acme": "acme"}
+
+	newServer := func(t *testing.T, clientAuth string) *httptest.Server {
+		certificates, err := tlsconfig.New(tlsconfig.Config{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: clientAuth})
+		require.NoError(t, err)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity := "anonymous"
+			if len(r.TLS.VerifiedChains) > 0 {
+				identity = identities.Identity(r.TLS.VerifiedChains[0][0])
+			}
+			w.Write([]byte(identity))
+		}))
+		server.TLS = certificates.TLSConfig()
+		server.StartTLS()
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	get := func(server *httptest.Server, certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		response, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		body, err := io.ReadAll(response.Body)
+		return string(body), err
+	}
+
+	clientCert := tls.Certificate{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}
+
+	t.Run("sucess should map verified client certificates to their identity", func(t *testing.T) {
+		// Arrange
+		server := newServer(t, tlsconfig.ClientAuthOptional)
+
+		// Act
+		withCert, err := get(server, clientCert)
+		require.NoError(t, err)
+		withoutCert, err := get(server)
+		require.NoError(t, err)
+
+		// Assert
+		require.Equal(t, "acme", withCert)
+		require.Equal(t, "anonymous", withoutCert)
+	})
+
+	t.Run("should reject clients without a certificate when one is required", func(t *testing.T) {
+		// Arrange
+		server := newServer(t, tlsconfig.ClientAuthRequire)
+
+		// Act
+		_, err := get(server)
+
+		// Assert
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,28 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/auth"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// APIKeyRequest checks the key to issue has a name, known scopes and, if it expires, expires after now.
+func APIKeyRequest(req utility.APIKeyRequest, now time.Time) error {
+	var v Violations
+
+	v.checkName(req.Name)
+
+	for _, scope := range req.Scopes {
+		if !auth.IsKnownScope(scope) {
+			v.Add("scopes", CodeInvalid, fmt.Sprintf("%q is not a known scope", scope))
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		v.Add("expires_at", CodeInvalid, "expires_at must be in the future")
+	}
+
+	return v.Err()
+}
@@ -0,0 +1,338 @@
+// Package validation checks requests field by field, reporting every violation at once.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/utility"
+)
+
+// Violation codes.
+const (
+	CodeRequired  = "required"
+	CodeMin       = "min"
+	CodeMaxLength = "max_length"
+	CodeEmpty     = "empty"
+	CodeInvalid   = "invalid"
+	CodeUnknown   = "unknown"
+	CodeImmutable = "immutable"
+)
+
+const (
+	maxNameLength      = 255
+	maxCodeValueLength = 64
+)
+
+// Violations collects the Violations found in a request, or in a row of an import.
+type Violations []utility.Violation
+
+func (v *Violations) Add(field, code, message string) {
+	*v = append(*v, utility.Violation{Field: field, Code: code, Message: message})
+}
+
+// Err returns a *utility.ValidationError holding v, or nil when v is empty.
+func (v Violations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &utility.ValidationError{Violations: v}
+}
+
+// Has reports whether v holds a violation of field.
+func (v Violations) Has(field string) bool {
+	for _, violation := range v {
+		if violation.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Violations) checkName(name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.Add("name", CodeRequired, "name is required")
+	case len(name) > maxNameLength:
+		v.Add("name", CodeMaxLength, fmt.Sprintf("name must be at most %d characters", maxNameLength))
+	}
+}
+
+// checkQuantity accepts products out of stock, since orders take quantities down to zero.
+func (v *Violations) checkQuantity(quantity int) {
+	if quantity < 0 {
+		v.Add("quantity", CodeMin, "quantity must not be negative")
+	}
+}
+
+func (v *Violations) checkCodeValue(codeValue string) {
+	switch {
+	case strings.TrimSpace(codeValue) == "":
+		v.Add("code_value", CodeRequired, "code_value is required")
+	case len(codeValue) > maxCodeValueLength:
+		v.Add("code_value", CodeMaxLength, fmt.Sprintf("code_value must be at most %d characters", maxCodeValueLength))
+	}
+}
+
+func (v *Violations) checkExpiration(expiration domain.Date) {
+	if expiration.IsZero() {
+		v.Add("expiration", CodeRequired, "expiration is required")
+	}
+}
+
+func (v *Violations) checkPrice(price domain.Money) {
+	switch {
+	case price.IsZero():
+		v.Add("price", CodeRequired, "price is required")
+	case price.Amount < 0:
+		v.Add("price", CodeMin, "price must be positive")
+	}
+}
+
+// ProductRequest checks every field of req. It returns a *utility.ValidationError listing the violations,
+// or nil when req is valid.
+func ProductRequest(req utility.ProductRequest) error {
+	var v Violations
+	v.checkName(req.Name)
+	v.checkQuantity(req.Quantity)
+	v.checkCodeValue(req.CodeValue)
+	v.checkExpiration(req.Expiration)
+	v.checkPrice(req.Price)
+	return v.Err()
+}
+
+// ProductPatchRequest checks every field set in req, which must set at least one. It returns
+// a *utility.ValidationError listing the violations, or nil when req is valid.
+func ProductPatchRequest(req utility.ProductPatchRequest) error {
+	var v Violations
+
+	if isEmptyPatch(req) {
+		v.Add("", CodeEmpty, "at least one field must be given")
+	}
+
+	if req.Name != nil {
+		v.checkName(*req.Name)
+	}
+	if req.Quantity != nil {
+		v.checkQuantity(*req.Quantity)
+	}
+	if req.CodeValue != nil {
+		v.checkCodeValue(*req.CodeValue)
+	}
+	if req.Expiration != nil {
+		v.checkExpiration(*req.Expiration)
+	}
+	if req.Price != nil {
+		v.checkPrice(*req.Price)
+	}
+
+	return v.Err()
+}
+
+func isEmptyPatch(req utility.ProductPatchRequest) bool {
+	return req.Name == nil && req.Quantity == nil && req.CodeValue == nil && req.IsPublished == nil &&
+		req.Expiration == nil && req.Price == nil
+}
+
+// ProductBulkPatchItem checks an item of a bulk patch: it must name a product, and a deletion cannot set
+// any field while a patch is checked as by ProductPatchRequest.
+func ProductBulkPatchItem(item utility.ProductBulkPatchItem) error {
+	var v Violations
+	if item.Id <= 0 {
+		v.Add("id", CodeRequired, "id is required")
+	}
+
+	if item.Delete {
+		if !isEmptyPatch(item.ProductPatchRequest) {
+			v.Add("delete", CodeInvalid, "delete cannot be combined with fields to change")
+		}
+		return v.Err()
+	}
+
+	var patchErr *utility.ValidationError
+	if errors.As(ProductPatchRequest(item.ProductPatchRequest), &patchErr) {
+		v = append(v, patchErr.Violations...)
+	}
+
+	return v.Err()
+}
+
+// DecodeMember decodes data into target, adding a violation of name when data is malformed.
+func (v *Violations) DecodeMember(name string, data json.RawMessage, target any) {
+	if err := json.Unmarshal(data, target); err != nil {
+		message := fmt.Sprintf("%s has an invalid value", name)
+		if errors.Is(err, domain.ErrInvalidDate) || errors.Is(err, domain.ErrInvalidMoney) {
+			message = fmt.Sprintf("%s: %v", name, err)
+		}
+		v.Add(name, CodeInvalid, message)
+	}
+}
+
+// DecodeMembers decodes every member of members into its target on its own, in name order, adding a violation for
+// each malformed member and for each member without a target, which is not a product field.
+func (v *Violations) DecodeMembers(members map[string]json.RawMessage, targets map[string]any) {
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		target, ok := targets[name]
+		if !ok {
+			v.Add(name, CodeUnknown, fmt.Sprintf("%s is not a product field", name))
+			continue
+		}
+
+		v.DecodeMember(name, members[name], target)
+	}
+}
+
+// decodeProductMembers decodes each member of body named in productFields into its target on its own, adding a
+// violation for every malformed one. Other members are ignored, as by json.Unmarshal. A body that is not a JSON
+// object is reported as utility.ErrInvalidRequestBody.
+func (v *Violations) decodeProductMembers(body []byte, targets map[string]any) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return utility.ErrInvalidRequestBody
+	}
+
+	for _, name := range productFields {
+		if data, ok := members[name]; ok {
+			v.DecodeMember(name, data, targets[name])
+		}
+	}
+	return nil
+}
+
+// productFields are the members of product requests, in the order their Violations are reported.
+var productFields = []string{"name", "quantity", "code_value", "is_published", "expiration", "price"}
+
+// DecodeProductRequest decodes body, the JSON of a product request, and checks it as ProductRequest does. Every member
+// is decoded on its own, so the returned *utility.ValidationError lists the malformed fields along with the invalid ones.
+func DecodeProductRequest(body []byte) (utility.ProductRequest, error) {
+	var v Violations
+	var req utility.ProductRequest
+
+	err := v.decodeProductMembers(body, map[string]any{
+		"name":         &req.Name,
+		"quantity":     &req.Quantity,
+		"code_value":   &req.CodeValue,
+		"is_published": &req.IsPublished,
+		"expiration":   &req.Expiration,
+		"price":        &req.Price,
+	})
+	if err != nil {
+		return utility.ProductRequest{}, err
+	}
+
+	var rulesErr *utility.ValidationError
+	if errors.As(ProductRequest(req), &rulesErr) {
+		for _, violation := range rulesErr.Violations {
+			if !v.Has(violation.Field) {
+				v = append(v, violation)
+			}
+		}
+	}
+
+	if err := v.Err(); err != nil {
+		return utility.ProductRequest{}, err
+	}
+	return req, nil
+}
+
+// DecodeProductPatchRequest decodes body, the JSON of a product patch, and checks it as ProductPatchRequest does.
+// Every member is decoded on its own, so the returned *utility.ValidationError lists the malformed fields along with
+// the invalid ones.
+func DecodeProductPatchRequest(body []byte) (utility.ProductPatchRequest, error) {
+	var v Violations
+	var req utility.ProductPatchRequest
+
+	err := v.decodeProductMembers(body, map[string]any{
+		"name":         &req.Name,
+		"quantity":     &req.Quantity,
+		"code_value":   &req.CodeValue,
+		"is_published": &req.IsPublished,
+		"expiration":   &req.Expiration,
+		"price":        &req.Price,
+	})
+	if err != nil {
+		return utility.ProductPatchRequest{}, err
+	}
+
+	// A patch whose only fields are malformed is not reported as empty.
+	var rulesErr *utility.ValidationError
+	if errors.As(ProductPatchRequest(req), &rulesErr) {
+		for _, violation := range rulesErr.Violations {
+			if !v.Has(violation.Field) && (violation.Code != CodeEmpty || len(v) == 0) {
+				v = append(v, violation)
+			}
+		}
+	}
+
+	if err := v.Err(); err != nil {
+		return utility.ProductPatchRequest{}, err
+	}
+	return req, nil
+}
+
+// PatchedProduct decodes doc, the JSON document of original after a patch, into the request to store it with.
+// Every member of doc is decoded and checked on its own, so the returned *utility.ValidationError lists
+// every unknown, malformed or invalid field. The id and the version, maintained by the repository, cannot be changed.
+func PatchedProduct(original domain.Product, doc []byte) (utility.ProductRequest, error) {
+	var v Violations
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &members); err != nil || members == nil {
+		v.Add("", CodeInvalid, "the patched product must be a JSON object")
+		return utility.ProductRequest{}, v.Err()
+	}
+
+	var id, version int
+	var req utility.ProductRequest
+	targets := map[string]any{
+		"id":           &id,
+		"version":      &version,
+		"name":         &req.Name,
+		"quantity":     &req.Quantity,
+		"code_value":   &req.CodeValue,
+		"is_published": &req.IsPublished,
+		"expiration":   &req.Expiration,
+		"price":        &req.Price,
+	}
+
+	v.DecodeMembers(members, targets)
+
+	if _, ok := members["id"]; !ok || (!v.Has("id") && id != original.Id) {
+		v.Add("id", CodeImmutable, "id cannot be changed")
+	}
+	if _, ok := members["version"]; !ok || (!v.Has("version") && version != original.Version) {
+		v.Add("version", CodeImmutable, "version cannot be changed")
+	}
+
+	if !v.Has("name") {
+		v.checkName(req.Name)
+	}
+	if !v.Has("quantity") {
+		v.checkQuantity(req.Quantity)
+	}
+	if !v.Has("code_value") {
+		v.checkCodeValue(req.CodeValue)
+	}
+	if !v.Has("expiration") {
+		v.checkExpiration(req.Expiration)
+	}
+	if !v.Has("price") {
+		v.checkPrice(req.Price)
+	}
+
+	if err := v.Err(); err != nil {
+		return utility.ProductRequest{}, err
+	}
+
+	return req, nil
+}
@@ -0,0 +1,109 @@
+package validation_test
+
+import (
+	"testing"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
+	"github.com/MDavidCV/go-web-module/internal/validation"
+	"github.com/MDavidCV/go-web-module/utility"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductRequest(t *testing.T) {
+	t.Run("sucess should accept a complete request", func(t *testing.T) {
+		// Arrange
+		req := utility.ProductRequest{Name: "test", Quantity: 0, CodeValue: "testcode", Expiration: domain.MustParseDate("15/12/2021"), Price: domain.MoneyFromFloat(99, "")}
+
+		// Act
+		err := validation.ProductRequest(req)
+
+		// Assert
+		require.NoError(t, err)
+	})
+
+	t.Run("should report every invalid field", func(t *testing.T) {
+		// Arrange
+		req := utility.ProductRequest{Name: " ", Quantity: -1, Price: domain.MoneyFromFloat(-5, "")}
+
+		// Act
+		err := validation.ProductRequest(req)
+
+		// Assert
+		var validationErr *utility.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.ErrorIs(t, err, utility.ErrValidation)
+		require.Equal(t, []utility.Violation{
+			{Field: "name", Code: validation.CodeRequired, Message: "name is required"},
+			{Field: "quantity", Code: validation.CodeMin, Message: "quantity must not be negative"},
+			{Field: "code_value", Code: validation.CodeRequired, Message: "code_value is required"},
+			{Field: "expiration", Code: validation.CodeRequired, Message: "expiration is required"},
+			{Field: "price", Code: validation.CodeMin, Message: "price must be positive"},
+		}, validationErr.Violations)
+	})
+}
+
+func TestProductPatchRequest(t *testing.T) {
+	t.Run("should check every given field, not only the first one", func(t *testing.T) {
+		// Arrange
+		name := "test"
+		codeValue := ""
+		req := utility.ProductPatchRequest{Name: &name, CodeValue: &codeValue}
+
+		// Act
+		err := validation.ProductPatchRequest(req)
+
+		// Assert
+		var validationErr *utility.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []utility.Violation{
+			{Field: "code_value", Code: validation.CodeRequired, Message: "code_value is required"},
+		}, validationErr.Violations)
+	})
+
+	t.Run("should reject an empty patch", func(t *testing.T) {
+		// Act
+		err := validation.ProductPatchRequest(utility.ProductPatchRequest{})
+
+		// Assert
+		var validationErr *utility.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, validation.CodeEmpty, validationErr.Violations[0].Code)
+	})
+}
+
+func TestDecodeProductPatchRequest(t *testing.T) {
+	t.Run("sucess should decode the given fields", func(t *testing.T) {
+		// Act
+		req, err := validation.DecodeProductPatchRequest([]byte(`{"quantity": 3, "price": 10.5}`))
+
+		// Assert
+		require.NoError(t, err)
+		require.Equal(t, 3, *req.Quantity)
+		require.Equal(t, domain.MoneyFromFloat(10.5, ""), *req.Price)
+		require.Nil(t, req.Name)
+	})
+
+	t.Run("should report malformed fields along with invalid ones", func(t *testing.T) {
+		// Act
+		_, err := validation.DecodeProductPatchRequest([]byte(`{"quantity": "x", "code_value": ""}`))
+
+		// Assert
+		var validationErr *utility.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []utility.Violation{
+			{Field: "quantity", Code: validation.CodeInvalid, Message: "quantity has an invalid value"},
+			{Field: "code_value", Code: validation.CodeRequired, Message: "code_value is required"},
+		}, validationErr.Violations)
+	})
+
+	t.Run("should not report a patch of malformed fields as empty", func(t *testing.T) {
+		// Act
+		_, err := validation.DecodeProductPatchRequest([]byte(`{"expiration": "soon"}`))
+
+		// Assert
+		var validationErr *utility.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Len(t, validationErr.Violations, 1)
+		require.Equal(t, "expiration", validationErr.Violations[0].Field)
+	})
+}
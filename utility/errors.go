@@ -1,6 +1,10 @@
 package utility
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 var ErrProductNotFound = errors.New("product not found")
 var ErrProductAlreadyExists = errors.New("product already exists")
@@ -10,3 +14,106 @@ var ErrUniqueCodeValue = errors.New("code value already exists")
 var ErrInvalidDate = errors.New("invalid expiration date")
 var ErrInvalidId = errors.New("invalid id")
 var ErrInvalidRequestBody = errors.New("invalid request body")
+var ErrMinimumOrder = errors.New("order below minimum amount")
+var ErrCurrencyMismatch = errors.New("products priced in different currencies")
+var ErrProductExpired = errors.New("product expired")
+var ErrInsufficientStock = errors.New("insufficient stock")
+var ErrProductUnavailable = errors.New("product not available")
+var ErrOrderNotFound = errors.New("order not found")
+var ErrOrderCancelled = errors.New("order already cancelled")
+var ErrCartNotFound = errors.New("cart not found")
+var ErrUnauthorized = errors.New("Unauthorized - Invalid Token")
+var ErrForbidden = errors.New("Forbidden - Insufficient Scope")
+var ErrAPIKeyNotFound = errors.New("api key not found")
+var ErrAPIKeyAlreadyExists = errors.New("api key already exists")
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+var ErrInvalidPatch = errors.New("invalid patch document")
+var ErrPatchFailed = errors.New("patch cannot be applied")
+var ErrPreconditionFailed = errors.New("precondition failed: the product was modified")
+var ErrBulkTooLarge = errors.New("too many items in bulk request")
+var ErrRequestTooLarge = errors.New("request body too large")
+var ErrBulkAborted = errors.New("not applied: another item of the batch failed")
+
+// Storage error kinds, used as the Kind of a StorageError.
+var ErrStorageUnavailable = errors.New("storage unavailable")
+var ErrCorruptData = errors.New("corrupt stored data")
+
+// StorageError is returned by the persistence layer when reading or writing products fails.
+// errors.Is matches both its Kind and the underlying Err.
+type StorageError struct {
+	// Kind is one of the storage error kinds, e.g. ErrStorageUnavailable.
+	Kind error
+	// Op describes the failed operation, e.g. "write products".
+	Op  string
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", e.Kind, e.Op, e.Err)
+}
+
+func (e *StorageError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+func NewStorageError(kind error, op string, err error) *StorageError {
+	return &StorageError{
+		Kind: kind,
+		Op:   op,
+		Err:  err,
+	}
+}
+
+var ErrValidation = errors.New("validation failed")
+
+// Violation is a field of a request that breaks a validation rule.
+type Violation struct {
+	// Field is the JSON name of the field, or empty when the rule applies to the whole request.
+	Field string `json:"field"`
+	// Code identifies the rule, e.g. "required", for clients to act on.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every violation found in a request. errors.Is matches ErrValidation.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Field+": "+violation.Message)
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(messages, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// AppError gives an error an explicit HTTP status, and optionally the problem type and title
+// reported for it in application/problem+json responses. It takes precedence over errorCodes.
+type AppError struct {
+	Status int
+	// Type is a URI identifying the kind of problem, "about:blank" when empty.
+	Type string
+	// Title is a short summary of the kind of problem, the status text when empty.
+	Title string
+	Err   error
+}
+
+func (e *AppError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func NewAppError(status int, err error) *AppError {
+	return &AppError{
+		Status: status,
+		Err:    err,
+	}
+}
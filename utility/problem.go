@@ -0,0 +1,69 @@
+package utility
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestId is the id of the request that failed, as sent in the X-Request-Id header.
+	RequestId string `json:"request_id,omitempty"`
+	// Errors lists the violations of a request that failed validation.
+	Errors []Violation `json:"errors,omitempty"`
+}
+
+// NewProblem describes err as problem details. instance is the URI of the request that failed.
+func NewProblem(err error, instance string, requestId string) Problem {
+	err = publicError(err)
+	status := errorCode(err)
+
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Error(),
+		Instance:  instance,
+		RequestId: requestId,
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		if appErr.Type != "" {
+			problem.Type = appErr.Type
+		}
+		if appErr.Title != "" {
+			problem.Title = appErr.Title
+		}
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Detail = ErrValidation.Error()
+		problem.Errors = validationErr.Violations
+	}
+
+	return problem
+}
+
+type requestIdKey struct{}
+
+// WithRequestId returns a copy of ctx carrying the id of the request being served.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestIdFromContext returns the request id stored in ctx by WithRequestId, or "".
+func RequestIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
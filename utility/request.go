@@ -7,91 +7,53 @@ import (
 )
 
 type ProductRequest struct {
-	Name        string  `json:"name"`
-	Quantity    int     `json:"quantity"`
-	CodeValue   string  `json:"code_value"`
-	IsPublished bool    `json:"is_published"`
-	Expiration  string  `json:"expiration"`
-	Price       float64 `json:"price"`
+	Name        string       `json:"name"`
+	Quantity    int          `json:"quantity"`
+	CodeValue   string       `json:"code_value"`
+	IsPublished bool         `json:"is_published"`
+	Expiration  domain.Date  `json:"expiration"`
+	Price       domain.Money `json:"price"`
 }
 
-func (pr *ProductRequest) VerifyNonZeroValues() bool {
-	return pr.Name != "" && pr.Quantity != 0 && pr.CodeValue != "" && pr.Expiration != "" && pr.Price != 0
-}
-
-func (pr *ProductRequest) VerifyUniqueCodeValue(products []domain.Product) bool {
-	for _, product := range products {
-		if product.CodeValue == pr.CodeValue {
-			return false
-		}
-	}
-	return true
+type ProductPatchRequest struct {
+	Name        *string       `json:"name,omitempty"`
+	Quantity    *int          `json:"quantity,omitempty"`
+	CodeValue   *string       `json:"code_value,omitempty"`
+	IsPublished *bool         `json:"is_published,omitempty"`
+	Expiration  *domain.Date  `json:"expiration,omitempty"`
+	Price       *domain.Money `json:"price,omitempty"`
 }
 
-func (pr *ProductRequest) VerifyExpirationDate() bool {
-	// Define the layout for parsing
-	layout := "02/01/2006"
-	_, err := time.Parse(layout, pr.Expiration)
-	return err == nil
+// ProductBulkPatchItem is one item of a bulk patch: the fields of ProductPatchRequest to change in the product Id,
+// or its deletion when Delete is set.
+type ProductBulkPatchItem struct {
+	Id int `json:"id"`
+	// Version, when set, must be the current version of the product, as the ETag of an If-Match header.
+	Version *int `json:"version,omitempty"`
+	Delete  bool `json:"delete,omitempty"`
+	ProductPatchRequest
 }
 
-type ProductPatchRequest struct {
-	Name        *string  `json:"name,omitempty"`
-	Quantity    *int     `json:"quantity,omitempty"`
-	CodeValue   *string  `json:"code_value,omitempty"`
-	IsPublished *bool    `json:"is_published,omitempty"`
-	Expiration  *string  `json:"expiration,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
+// OrderRequest lists the ids of the ordered products, an id appearing once per unit.
+type OrderRequest struct {
+	List []int `json:"list"`
 }
 
-func (ppr *ProductPatchRequest) VerifyNonZeroValues() bool {
-	if ppr.Name != nil {
-		return *ppr.Name != ""
-	}
-
-	if ppr.Quantity != nil {
-		return *ppr.Quantity != 0
-	}
-
-	if ppr.CodeValue != nil {
-		return *ppr.CodeValue != ""
-	}
-
-	if ppr.IsPublished != nil {
-		return true
-	}
-
-	if ppr.Expiration != nil {
-		return *ppr.Expiration != ""
-	}
-
-	if ppr.Price != nil {
-		return *ppr.Price != 0
-	}
-
-	return false
+// CartItemRequest adds Quantity units of a product to a cart.
+type CartItemRequest struct {
+	ProductId int `json:"product_id"`
+	Quantity  int `json:"quantity"`
 }
 
-func (ppr *ProductPatchRequest) VerifyUniqueCodeValue(products []domain.Product) bool {
-	if ppr.CodeValue == nil {
-		return true
-	}
-
-	for _, product := range products {
-		if product.CodeValue == *ppr.CodeValue {
-			return false
-		}
-	}
-	return true
+// CartRequest holds the items a cart is created with, if any.
+type CartRequest struct {
+	Items []CartItemRequest `json:"items"`
 }
 
-func (ppr *ProductPatchRequest) VerifyExpirationDate() bool {
-	if ppr.Expiration == nil {
-		return true
-	}
-
-	// Define the layout for parsing
-	layout := "02/01/2006"
-	_, err := time.Parse(layout, *ppr.Expiration)
-	return err == nil
+// APIKeyRequest describes an API key to issue. The key gets the roles of the access policy when Scopes is empty,
+// and never expires when ExpiresAt is nil.
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
 }
@@ -1,34 +1,178 @@
 package utility
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"time"
+
+	"github.com/MDavidCV/go-web-module/internal/domain"
 )
 
-var errorCodes = map[error]int{
-	ErrInvalidId:            http.StatusBadRequest,
-	ErrProductNotFound:      http.StatusNotFound,
-	ErrInvalidQuery:         http.StatusBadRequest,
-	ErrInvalidDate:          http.StatusBadRequest,
-	ErrUniqueCodeValue:      http.StatusBadRequest,
-	ErrInvalidValues:        http.StatusBadRequest,
-	ErrProductAlreadyExists: http.StatusInternalServerError,
-	ErrInvalidRequestBody:   http.StatusBadRequest,
+// errorCodes maps errors to the status of their responses. An error wrapping several of them gets the status of
+// the first one, so more specific errors come first.
+var errorCodes = []struct {
+	target error
+	code   int
+}{
+	{ErrInvalidId, http.StatusBadRequest},
+	{ErrProductNotFound, http.StatusNotFound},
+	{ErrInvalidQuery, http.StatusBadRequest},
+	{ErrInvalidDate, http.StatusBadRequest},
+	{ErrUniqueCodeValue, http.StatusBadRequest},
+	{ErrInvalidValues, http.StatusBadRequest},
+	{ErrProductAlreadyExists, http.StatusInternalServerError},
+	{ErrBulkTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrRequestTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrInvalidRequestBody, http.StatusBadRequest},
+	{ErrMinimumOrder, http.StatusBadRequest},
+	{ErrCurrencyMismatch, http.StatusBadRequest},
+	{ErrProductExpired, http.StatusBadRequest},
+	{ErrInsufficientStock, http.StatusConflict},
+	{ErrProductUnavailable, http.StatusConflict},
+	{ErrOrderNotFound, http.StatusNotFound},
+	{ErrOrderCancelled, http.StatusConflict},
+	{ErrCartNotFound, http.StatusNotFound},
+	{ErrStorageUnavailable, http.StatusServiceUnavailable},
+	{ErrCorruptData, http.StatusInternalServerError},
+	{ErrValidation, http.StatusUnprocessableEntity},
+	{ErrUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrAPIKeyNotFound, http.StatusNotFound},
+	{ErrAPIKeyAlreadyExists, http.StatusConflict},
+	{ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+	{ErrInvalidPatch, http.StatusBadRequest},
+	{ErrPatchFailed, http.StatusConflict},
+	{ErrPreconditionFailed, http.StatusPreconditionFailed},
+	{ErrBulkAborted, http.StatusFailedDependency},
+	{domain.ErrMoneyOverflow, http.StatusUnprocessableEntity},
 }
 
 type Response struct {
 	Code  int         `json:"code"`
 	Data  interface{} `json:"body"`
 	Error string      `json:"error"`
+	Meta  *PageMeta   `json:"meta,omitempty"`
+}
+
+// PageMeta describes the page returned by a paginated list endpoint.
+type PageMeta struct {
+	// Total is the number of items of the whole list, Count the number in this page.
+	Total  int `json:"total"`
+	Count  int `json:"count"`
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset"`
+	// NextCursor and PrevCursor can be sent back as the cursor parameter to move between pages.
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	// Next and Prev are ready to use links to the following and preceding pages.
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// BulkReport is the body of the response to a bulk request. Results holds the outcome of each item,
+// in the order of the request, as the response a single request for the item would have got.
+type BulkReport struct {
+	Mode      string     `json:"mode"`
+	Succeeded int        `json:"succeeded"`
+	Failed    int        `json:"failed"`
+	Results   []Response `json:"results"`
+}
+
+// APIKeyResponse describes an API key without its hash. Key, the secret clients authenticate with, is only
+// returned when the key is issued or rotated.
+type APIKeyResponse struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+// ImportRowResult is the outcome of the row at Line of an import, as the response to its creation alone would be.
+type ImportRowResult struct {
+	Line int `json:"line"`
+	Response
+}
+
+// ImportReport is the body of the response to an import, with the outcome of every row.
+type ImportReport struct {
+	Format    string            `json:"format"`
+	Mode      string            `json:"mode"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []ImportRowResult `json:"results"`
+}
+
+// publicError returns the part of err that can be shown to clients. Storage errors carry file paths
+// and driver messages: they are logged and only their kind is exposed. Other unexpected errors, answered with
+// a 5xx status by neither an AppError nor errorCodes, are logged and exposed as their status text.
+func publicError(err error) error {
+	var storageErr *StorageError
+	if errors.As(err, &storageErr) {
+		log.Printf("storage error: %v", err)
+		return storageErr.Kind
+	}
+
+	var appErr *AppError
+	if !isErrorCode(err) && !errors.As(err, &appErr) && errorCode(err) >= http.StatusInternalServerError {
+		log.Printf("internal error: %v", err)
+		return errors.New(http.StatusText(errorCode(err)))
+	}
+
+	return err
 }
 
 func NewErrorResponse(err error) Response {
+	err = publicError(err)
+
+	// Validation errors carry the violations in the body, so clients can point at each field.
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return Response{
+			Code:  errorCode(err),
+			Data:  validationErr.Violations,
+			Error: ErrValidation.Error(),
+		}
+	}
+
 	return Response{
-		Code:  errorCodes[err],
+		Code:  errorCode(err),
 		Data:  nil,
 		Error: err.Error(),
 	}
 }
 
+// errorCode returns the status of err: the one of an AppError it wraps, else the one of the first error of
+// errorCodes it wraps, else 500.
+func errorCode(err error) int {
+	var appErr *AppError
+	if errors.As(err, &appErr) && appErr.Status != 0 {
+		return appErr.Status
+	}
+
+	for _, errorCode := range errorCodes {
+		if errors.Is(err, errorCode.target) {
+			return errorCode.code
+		}
+	}
+
+	return http.StatusInternalServerError
+}
+
+// isErrorCode reports whether err is itself one of the errors of errorCodes.
+func isErrorCode(err error) bool {
+	for _, errorCode := range errorCodes {
+		if err == errorCode.target {
+			return true
+		}
+	}
+	return false
+}
+
 func NewSuccessResponse(data interface{}) Response {
 	return Response{
 		Code:  http.StatusOK,
@@ -37,10 +181,12 @@ func NewSuccessResponse(data interface{}) Response {
 	}
 }
 
+func NewPageResponse(data interface{}, meta PageMeta) Response {
+	response := NewSuccessResponse(data)
+	response.Meta = &meta
+	return response
+}
+
 func NewUnauthorizedResponse() Response {
-	return Response{
-		Code:  http.StatusUnauthorized,
-		Data:  nil,
-		Error: "Unauthorized - Invalid Token",
-	}
+	return NewErrorResponse(ErrUnauthorized)
 }